@@ -0,0 +1,214 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CommonLogFormat = `%h %l %u %t "%r" %>s %b`
+	CombinedLogFormat = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`
+)
+
+const accessTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessEntry describes a completed HTTP request.  Time is when the request
+// started, in the time zone it should be logged in.
+type AccessEntry struct {
+	Request *http.Request
+	Time time.Time
+	Status int
+	Bytes int64
+	Duration time.Duration
+	ResponseHeader http.Header
+}
+
+type accessPart struct {
+	literal string
+	directive byte
+	arg string
+}
+
+// AccessLog writes requests in an Apache-style log format.  The supported
+// directives are %a, %h, %l, %u, %t, %r, %s, %>s, %b, %B, %D, %T, %m, %U,
+// %q, %H, %v, %{Name}i, %{Name}o and %%.
+type AccessLog struct {
+	w io.Writer
+	out *lockedWriter
+	format string
+	parts []accessPart
+}
+
+func NewAccessLog(w io.Writer, format string) (*AccessLog, error) {
+	parts, err := parseAccessFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessLog{
+		w: w,
+		out: &lockedWriter{w: w},
+		format: format,
+		parts: parts,
+	}, nil
+}
+
+func parseAccessFormat(format string) ([]accessPart, error) {
+	parts := []accessPart{}
+	literal := ""
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal += format[i:i+1]
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return nil, errors.Errorf("incomplete directive at end of access log format %q", format)
+		}
+		if format[i] == '%' {
+			literal += "%"
+			continue
+		}
+		part := accessPart{}
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated %%{ in access log format %q", format)
+			}
+			part.arg = format[i+1:i+end]
+			i += end + 1
+		} else if format[i] == '>' {
+			i++
+		}
+		if i >= len(format) {
+			return nil, errors.Errorf("incomplete directive at end of access log format %q", format)
+		}
+		part.directive = format[i]
+		switch part.directive {
+		case 'i', 'o':
+			if part.arg == "" {
+				return nil, errors.Errorf("%%%c needs a header name in access log format %q", part.directive, format)
+			}
+		case 'a', 'h', 'l', 'u', 't', 'r', 's', 'b', 'B', 'D', 'T', 'm', 'U', 'q', 'H', 'v':
+		default:
+			return nil, errors.Errorf("unknown directive %%%c in access log format %q", part.directive, format)
+		}
+		if literal != "" {
+			parts = append(parts, accessPart{literal: literal})
+			literal = ""
+		}
+		parts = append(parts, part)
+	}
+	if literal != "" {
+		parts = append(parts, accessPart{literal: literal})
+	}
+	return parts, nil
+}
+
+func (al *AccessLog) Writer() io.Writer {
+	return al.w
+}
+
+func (al *AccessLog) Format() string {
+	return al.format
+}
+
+// accessEscape escapes quotes, backslashes and non-printable characters
+// the way Apache does
+func accessEscape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			sb.WriteString(fmt.Sprintf(`\x%02x`, c))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func (part accessPart) render(e *AccessEntry) string {
+	r := e.Request
+	switch part.directive {
+	case 0:
+		return part.literal
+	case 'a', 'h':
+		return orDash(remoteIP(r.RemoteAddr))
+	case 'l':
+		return "-"
+	case 'u':
+		user, _, _ := r.BasicAuth()
+		return orDash(accessEscape(user))
+	case 't':
+		return "[" + e.Time.Format(accessTimeFormat) + "]"
+	case 'r':
+		return accessEscape(r.Method + " " + r.URL.RequestURI() + " " + r.Proto)
+	case 's':
+		return strconv.Itoa(e.Status)
+	case 'b':
+		if e.Bytes == 0 {
+			return "-"
+		}
+		return strconv.FormatInt(e.Bytes, 10)
+	case 'B':
+		return strconv.FormatInt(e.Bytes, 10)
+	case 'D':
+		return strconv.FormatInt(int64(e.Duration / time.Microsecond), 10)
+	case 'T':
+		return strconv.FormatInt(int64(e.Duration / time.Second), 10)
+	case 'm':
+		return accessEscape(r.Method)
+	case 'U':
+		return accessEscape(r.URL.EscapedPath())
+	case 'q':
+		if r.URL.RawQuery == "" {
+			return ""
+		}
+		return "?" + accessEscape(r.URL.RawQuery)
+	case 'H':
+		return accessEscape(r.Proto)
+	case 'v':
+		return orDash(accessEscape(r.Host))
+	case 'i':
+		return orDash(accessEscape(r.Header.Get(part.arg)))
+	case 'o':
+		return orDash(accessEscape(e.ResponseHeader.Get(part.arg)))
+	}
+	return ""
+}
+
+func (al *AccessLog) Log(e *AccessEntry) error {
+	var sb strings.Builder
+	for _, part := range al.parts {
+		sb.WriteString(part.render(e))
+	}
+	sb.WriteByte('\n')
+	_, err := al.out.Write([]byte(sb.String()))
+	return err
+}
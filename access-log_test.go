@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type AccessLogSuite struct {}
+var _ = Suite(&AccessLogSuite{})
+
+func accessEntry() *AccessEntry {
+	req := httptest.NewRequest("GET", "/apache_pb.gif?x=1", nil)
+	req.RemoteAddr = "127.0.0.1:5555"
+	req.SetBasicAuth("frank", "secret")
+	req.Header.Set("Referer", "http://www.example.com/start.html")
+	req.Header.Set("User-Agent", `Mozilla/4.08 [en] (Win98; I ;Nav) "quoted"`)
+	tz := time.FixedZone("MDT", -6 * 3600)
+	return &AccessEntry{
+		Request: req,
+		Time: time.Date(2000, time.October, 10, 13, 55, 36, 0, tz),
+		Status: 200,
+		Bytes: 2326,
+		Duration: 1500 * time.Millisecond,
+		ResponseHeader: http.Header{"Content-Type": []string{"image/gif"}},
+	}
+}
+
+func (a *AccessLogSuite) TestCommon(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	al, err := NewAccessLog(buf, CommonLogFormat)
+	c.Assert(err, IsNil)
+	c.Check(al.Writer(), Equals, buf)
+	c.Check(al.Format(), Equals, CommonLogFormat)
+	c.Check(al.Log(accessEntry()), IsNil)
+	c.Check(buf.String(), Equals, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0600] "GET /apache_pb.gif?x=1 HTTP/1.1" 200 2326` + "\n")
+}
+
+func (a *AccessLogSuite) TestCombined(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	al, err := NewAccessLog(buf, CombinedLogFormat)
+	c.Assert(err, IsNil)
+	e := accessEntry()
+	e.Bytes = 0
+	e.Request.Header.Del("Authorization")
+	e.Request.Header.Del("Referer")
+	c.Check(al.Log(e), IsNil)
+	c.Check(buf.String(), Equals, `127.0.0.1 - - [10/Oct/2000:13:55:36 -0600] "GET /apache_pb.gif?x=1 HTTP/1.1" 200 - "-" "Mozilla/4.08 [en] (Win98; I ;Nav) \"quoted\""` + "\n")
+}
+
+func (a *AccessLogSuite) TestCustom(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	al, err := NewAccessLog(buf, `%a %v %m %U%q %H %s %>s %B %D %T %{Content-Type}o %{X-Missing}i 100%%`)
+	c.Assert(err, IsNil)
+	e := accessEntry()
+	e.Request.Method = "GET\n"
+	c.Check(al.Log(e), IsNil)
+	c.Check(buf.String(), Equals, `127.0.0.1 example.com GET\x0a /apache_pb.gif?x=1 HTTP/1.1 200 200 2326 1500000 1 image/gif - 100%` + "\n")
+}
+
+func (a *AccessLogSuite) TestBadFormat(c *C) {
+	_, err := NewAccessLog(nil, `%h %Z`)
+	c.Check(err, ErrorMatches, `unknown directive %Z in access log format "%h %Z"`)
+	_, err = NewAccessLog(nil, `%h %`)
+	c.Check(err, ErrorMatches, `incomplete directive at end of access log format .*`)
+	_, err = NewAccessLog(nil, `%{Referer`)
+	c.Check(err, ErrorMatches, `unterminated %{ in access log format .*`)
+	_, err = NewAccessLog(nil, `%i`)
+	c.Check(err, ErrorMatches, `%i needs a header name in access log format .*`)
+}
+
+func (a *AccessLogSuite) TestMiddleware(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, INFO)
+	l.SetTimeZone(time.FixedZone("XYZ", 5 * 3600 + 1800))
+	fn := filepath.Join(c.MkDir(), "access.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	defer rf.Close()
+	al, err := NewAccessLog(rf, CombinedLogFormat)
+	c.Assert(err, IsNil)
+	m := NewHTTPMiddleware(l)
+	m.SetAccessLog(al)
+	c.Check(m.AccessLog(), Equals, al)
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	req := httptest.NewRequest("POST", "/missing", nil)
+	req.RemoteAddr = "[::1]:8080"
+	req.Header.Set("User-Agent", "test")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	l.Flush()
+	c.Check(readFile(c, fn), Matches, `::1 - - \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d \+0530\] "POST /missing HTTP/1.1" 404 19 "-" "test"`+"\n")
+	c.Check(buf.String(), Matches, `(?s).*POST /missing 404.*`)
+	c.Check(buf.String(), Not(Matches), `(?s).*"POST.*`)
+}
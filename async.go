@@ -0,0 +1,179 @@
+package logging
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = 0
+	OverflowDropNewest OverflowPolicy = 1
+	OverflowDropOldest OverflowPolicy = 2
+)
+
+const DefaultQueueSize = 1024
+
+type asyncEntry struct {
+	l *Logger
+	rec *Record
+	seq uint64
+}
+
+// asyncQueue writes records in order on a worker goroutine.  The worker
+// starts when entries arrive and exits once the queue is empty, so idle
+// loggers hold no goroutines.
+type asyncQueue struct {
+	lock sync.RWMutex
+	entries chan *asyncEntry
+	policy OverflowPolicy
+	closed bool
+	runLock sync.Mutex
+	running bool
+	worker uint64
+	// sendLock keeps entries in the channel in sequence order
+	sendLock sync.Mutex
+	enqueued uint64
+	doneLock sync.Mutex
+	doneCond *sync.Cond
+	processed uint64
+	dropped uint64
+}
+
+func newAsyncQueue(size int, policy OverflowPolicy) *asyncQueue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	q := &asyncQueue{
+		entries: make(chan *asyncEntry, size),
+		policy: policy,
+	}
+	q.doneCond = sync.NewCond(&q.doneLock)
+	return q
+}
+
+func (q *asyncQueue) run() {
+	atomic.StoreUint64(&q.worker, goroutineID())
+	for {
+		select {
+		case e, ok := <-q.entries:
+			if !ok {
+				q.stop()
+				return
+			}
+			e.l.WriteRecord(e.rec)
+			q.done(e.seq)
+		default:
+			// enqueue sends before it checks running, so an entry
+			// arriving now is either seen here or starts a new worker
+			q.runLock.Lock()
+			if len(q.entries) == 0 {
+				q.running = false
+				q.runLock.Unlock()
+				return
+			}
+			q.runLock.Unlock()
+		}
+	}
+}
+
+func (q *asyncQueue) stop() {
+	q.runLock.Lock()
+	q.running = false
+	q.runLock.Unlock()
+}
+
+func (q *asyncQueue) wake() {
+	q.runLock.Lock()
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+	q.runLock.Unlock()
+}
+
+func (q *asyncQueue) done(seq uint64) {
+	q.doneLock.Lock()
+	q.processed = seq
+	q.doneCond.Broadcast()
+	q.doneLock.Unlock()
+}
+
+func (q *asyncQueue) drop() {
+	atomic.AddUint64(&q.dropped, 1)
+}
+
+func (q *asyncQueue) enqueue(e *asyncEntry) bool {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.closed {
+		return false
+	}
+	q.sendLock.Lock()
+	defer q.sendLock.Unlock()
+	e.seq = q.enqueued + 1
+	switch q.policy {
+	case OverflowDropNewest:
+		select {
+		case q.entries <- e:
+		default:
+			q.drop()
+			return true
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.entries <- e:
+				atomic.StoreUint64(&q.enqueued, e.seq)
+				q.wake()
+				return true
+			default:
+			}
+			select {
+			case <-q.entries:
+				q.drop()
+			default:
+			}
+		}
+	default:
+		q.entries <- e
+	}
+	atomic.StoreUint64(&q.enqueued, e.seq)
+	q.wake()
+	return true
+}
+
+// flush waits until the entries enqueued before the call have been
+// written or dropped.  Entries leave the channel in sequence order, and
+// the newest entry is never dropped, so that's once the worker has
+// written one at least as new as the last enqueued.  It returns at once
+// when called from the worker itself, which would otherwise wait on the
+// entry it's writing.
+func (q *asyncQueue) flush() {
+	target := atomic.LoadUint64(&q.enqueued)
+	if target == 0 || atomic.LoadUint64(&q.worker) == goroutineID() {
+		return
+	}
+	q.doneLock.Lock()
+	for q.processed < target {
+		q.doneCond.Wait()
+	}
+	q.doneLock.Unlock()
+}
+
+// close stops the queue once everything in it has been written.  Later
+// entries are written synchronously.
+func (q *asyncQueue) close() {
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.entries)
+	}
+	q.lock.Unlock()
+	q.flush()
+}
+
+func (q *asyncQueue) droppedCount() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
@@ -0,0 +1,255 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type AsyncSuite struct {}
+var _ = Suite(&AsyncSuite{})
+
+type gatedWriter struct {
+	gate chan struct{}
+	started chan struct{}
+	once sync.Once
+	buf *bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{
+		gate: make(chan struct{}),
+		started: make(chan struct{}),
+		buf: bytes.NewBuffer([]byte{}),
+	}
+}
+
+func (w *gatedWriter) Write(data []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.gate
+	return w.buf.Write(data)
+}
+
+func (a *AsyncSuite) TestOrder(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	for i := 0; i < 2000; i++ {
+		l.Info(i)
+	}
+	l.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 2000)
+	for i, line := range lines {
+		c.Check(line, Equals, fmt.Sprintf("INFO     %d", i))
+	}
+	c.Check(l.Dropped(), Equals, uint64(0))
+}
+
+func (a *AsyncSuite) TestDropNewest(c *C) {
+	w := newGatedWriter()
+	l := NewLogger(w, DEBUG).WithAsyncQueue(2, OverflowDropNewest)
+	l.SetFlags(0)
+	l.Info(0)
+	<-w.started
+	for i := 1; i <= 5; i++ {
+		l.Info(i)
+	}
+	close(w.gate)
+	l.Flush()
+	c.Check(w.buf.String(), Equals, "INFO     0\nINFO     1\nINFO     2\n")
+	c.Check(l.Dropped(), Equals, uint64(3))
+}
+
+func (a *AsyncSuite) TestDropOldest(c *C) {
+	w := newGatedWriter()
+	l := NewLogger(w, DEBUG).WithAsyncQueue(2, OverflowDropOldest)
+	l.SetFlags(0)
+	l.Info(0)
+	<-w.started
+	for i := 1; i <= 5; i++ {
+		l.Info(i)
+	}
+	close(w.gate)
+	l.Flush()
+	c.Check(w.buf.String(), Equals, "INFO     0\nINFO     4\nINFO     5\n")
+	c.Check(l.Dropped(), Equals, uint64(3))
+}
+
+func (a *AsyncSuite) TestClose(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	l.Info("before")
+	l.Close()
+	c.Check(buf.String(), Equals, "INFO     before\n")
+	l.Info("after")
+	c.Check(buf.String(), Equals, "INFO     before\nINFO     after\n")
+	l.Close()
+	NewLogger(buf, DEBUG).Close()
+}
+
+func (a *AsyncSuite) TestFatalFlushes(c *C) {
+	exitStatus := -1
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	exiter = func(n int) {
+		exitStatus = n
+		c.Check(buf.String(), Equals, "ERROR    first\nWARNING  second\nCRITICAL third\n")
+	}
+	l.Error("first")
+	l.Warn("second")
+	l.Fatal("third")
+	c.Check(exitStatus, Equals, 1)
+}
+
+type slowWriter struct {
+	lock sync.Mutex
+	lines []string
+}
+
+func (w *slowWriter) Write(data []byte) (int, error) {
+	time.Sleep(20 * time.Microsecond)
+	w.lock.Lock()
+	w.lines = append(w.lines, string(data))
+	w.lock.Unlock()
+	return len(data), nil
+}
+
+func (w *slowWriter) contains(s string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, line := range w.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AsyncSuite) TestSyncWithConcurrentProducers(c *C) {
+	w := &slowWriter{}
+	l := NewLogger(w, DEBUG)
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					l.Info("async")
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	synced := make(chan struct{})
+	go func() {
+		l.RawLogSync(nil, ERROR, "sync")
+		close(synced)
+	}()
+	select {
+	case <-synced:
+	case <-time.After(2 * time.Second):
+		c.Error("sync write blocked behind concurrent async writes")
+	}
+	close(stop)
+	wg.Wait()
+	l.Flush()
+	c.Check(w.contains("sync"), Equals, true)
+}
+
+type reentrantSink struct {
+	l *Logger
+	buf *bytes.Buffer
+}
+
+func (s *reentrantSink) Level() LogLevel {
+	return DEBUG
+}
+
+func (s *reentrantSink) Log(rec *Record) error {
+	if rec.Message == "trigger" {
+		s.l.RawLogSync(nil, ERROR, "from sink")
+	}
+	s.buf.WriteString(rec.Message + "\n")
+	return nil
+}
+
+func (a *AsyncSuite) TestSyncFromWorker(c *C) {
+	l := NewLogger(nil, DEBUG)
+	s := &reentrantSink{l: l, buf: bytes.NewBuffer([]byte{})}
+	l.AddSink(s)
+	l.Info("trigger")
+	done := make(chan struct{})
+	go func() {
+		l.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		c.Fatal("sync write from the async worker deadlocked")
+	}
+	c.Check(s.buf.String(), Equals, "from sink\ntrigger\n")
+}
+
+// settledGoroutines waits for exiting goroutines to finish and reports
+// whether the count came back down to at most n
+func settledGoroutines(n int) bool {
+	for i := 0; i < 200; i++ {
+		if runtime.NumGoroutine() <= n {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func (a *AsyncSuite) TestWorkersExit(c *C) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 1000; i++ {
+		l := NewLogger(bytes.NewBuffer([]byte{}), DEBUG)
+		l.Info(i)
+		l.Flush()
+	}
+	c.Check(settledGoroutines(before), Equals, true, Commentf("%d goroutines, was %d", runtime.NumGoroutine(), before))
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	for i := 0; i < 10; i++ {
+		l.Info(i)
+		l.SetAsyncQueue(4, OverflowBlock)
+	}
+	l.Info("last")
+	l.Flush()
+	c.Check(strings.Count(buf.String(), "\n"), Equals, 11)
+	c.Check(settledGoroutines(before), Equals, true, Commentf("%d goroutines, was %d", runtime.NumGoroutine(), before))
+}
+
+func (a *AsyncSuite) TestCloseClone(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	child := l.WithPrefix("child")
+	child.Info("from child")
+	child.Close()
+	c.Check(buf.String(), Equals, "INFO     child from child\n")
+	c.Check(l.cfg().async.closed, Equals, false)
+	own := l.WithAsyncQueue(8, OverflowBlock)
+	own.Close()
+	c.Check(own.cfg().async.closed, Equals, true)
+	c.Check(l.cfg().async.closed, Equals, false)
+	l.Close()
+	c.Check(l.cfg().async.closed, Equals, true)
+}
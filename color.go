@@ -161,3 +161,74 @@ func (c *Colorizer) Colorize(message string) string {
 	return c.escape + message + "\033[0m"
 }
 
+
+var fontNames = []struct{
+	font FontCode
+	name string
+}{
+	{FontBold, "bold"},
+	{FontLight, "light"},
+	{FontItalic, "italic"},
+	{FontUnderline, "underline"},
+	{FontBlink, "blink"},
+	{FontReverse, "reverse"},
+}
+
+func (cc ColorCode) MarshalText() ([]byte, error) {
+	return []byte(cc), nil
+}
+
+// UnmarshalText accepts color names case-insensitively, with hyphens or
+// underscores in place of spaces, eg. "Hot-Pink".  An empty string is
+// ColorDefault.
+func (cc *ColorCode) UnmarshalText(data []byte) error {
+	s := strings.ToLower(strings.TrimSpace(string(data)))
+	s = strings.NewReplacer("-", " ", "_", " ").Replace(s)
+	if s == "" {
+		*cc = ColorDefault
+		return nil
+	}
+	if _, ok := colorEscapes[ColorCode(s)]; !ok {
+		return errors.Errorf("unknown color '%s'", string(data))
+	}
+	*cc = ColorCode(s)
+	return nil
+}
+
+func (fc FontCode) MarshalText() ([]byte, error) {
+	names := []string{}
+	for _, fn := range fontNames {
+		if fc & fn.font != 0 {
+			names = append(names, fn.name)
+		}
+	}
+	if len(names) == 0 {
+		return []byte("default"), nil
+	}
+	return []byte(strings.Join(names, "|")), nil
+}
+
+// UnmarshalText parses a list of font names separated by "|", eg.
+// "bold|blink".
+func (fc *FontCode) UnmarshalText(data []byte) error {
+	font := FontDefault
+	for _, name := range strings.Split(string(data), "|") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "default" {
+			continue
+		}
+		found := false
+		for _, fn := range fontNames {
+			if fn.name == name {
+				font |= fn.font
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("unknown font '%s'", name)
+		}
+	}
+	*fc = font
+	return nil
+}
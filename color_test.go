@@ -21,3 +21,34 @@ func (a *ColorSuite) TestNewColorizer(c *C) {
 	c.Check(cz.escape, Equals, "")
 	c.Check(cz.Colorize("abcd"), Equals, "abcd")
 }
+
+func (a *ColorSuite) TestColorText(c *C) {
+	var cc ColorCode
+	c.Check(cc.UnmarshalText([]byte("Hot-Pink")), IsNil)
+	c.Check(cc, Equals, ColorHotPink)
+	c.Check(cc.UnmarshalText([]byte("light_gray")), IsNil)
+	c.Check(cc, Equals, ColorLightGray)
+	c.Check(cc.UnmarshalText([]byte("")), IsNil)
+	c.Check(cc, Equals, ColorDefault)
+	c.Check(cc.UnmarshalText([]byte("fuschia")), ErrorMatches, `unknown color 'fuschia'`)
+	data, err := ColorTurquoise.MarshalText()
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "turquoise")
+}
+
+func (a *ColorSuite) TestFontText(c *C) {
+	var fc FontCode
+	c.Check(fc.UnmarshalText([]byte("bold|blink")), IsNil)
+	c.Check(fc, Equals, FontBold | FontBlink)
+	c.Check(fc.UnmarshalText([]byte(" Italic | underline ")), IsNil)
+	c.Check(fc, Equals, FontItalic | FontUnderline)
+	c.Check(fc.UnmarshalText([]byte("default")), IsNil)
+	c.Check(fc, Equals, FontDefault)
+	c.Check(fc.UnmarshalText([]byte("bold|wavy")), ErrorMatches, `unknown font 'wavy'`)
+	data, err := (FontBold | FontReverse).MarshalText()
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "bold|reverse")
+	data, err = FontDefault.MarshalText()
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "default")
+}
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConcurrencySuite struct {}
+var _ = Suite(&ConcurrencySuite{})
+
+func (a *ConcurrencySuite) TestReconfigureWhileLogging(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetSourceFormat("")
+	l.SetTimeFormat("")
+	l.SetPrefix("even")
+	stop := make(chan struct{})
+	cfgDone := make(chan struct{})
+	go func() {
+		defer close(cfgDone)
+		colors := []ColorCode{ColorRed, ColorGreen, ColorBlue}
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i % 2 == 0 {
+				l.SetLevel(DEBUG)
+				l.SetPrefix("even")
+			} else {
+				l.SetLevel(TRACE)
+				l.SetPrefix("odd")
+			}
+			l.SetLevelColor(INFO, colors[i % len(colors)], ColorDefault, FontDefault)
+			l.SetTimeFormat("")
+			_ = l.WithField("iteration", i).WithColor().LevelColor(INFO)
+		}
+	}()
+	wg := &sync.WaitGroup{}
+	workers := 8
+	count := 500
+	for g := 0; g < workers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if i % 10 == 0 {
+					l.RawLogSync(nil, WARNING, fmt.Sprintf("%d g=%d", i, g))
+				} else {
+					l.Infof("%d g=%d", i, g)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(stop)
+	<-cfgDone
+	l.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Check(lines, HasLen, workers * count)
+	re := regexp.MustCompile(`^(INFO|WARNING) +(even|odd) ([0-9]+) g=([0-9]+)$`)
+	last := map[string]int{}
+	for _, line := range lines {
+		m := re.FindStringSubmatch(line)
+		if !c.Check(m, NotNil, Commentf("bad line %q", line)) {
+			continue
+		}
+		i, _ := strconv.Atoi(m[3])
+		if prev, ok := last[m[4]]; ok {
+			c.Check(i > prev, Equals, true, Commentf("goroutine %s wrote %d after %d", m[4], i, prev))
+		}
+		last[m[4]] = i
+	}
+}
+
+func (a *ConcurrencySuite) TestClonesDoNotShareColors(c *C) {
+	l := NewLogger(bytes.NewBuffer([]byte{}), DEBUG)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cl := l.WithLevelColor(ERROR, ColorHotPink, ColorDefault, FontDefault)
+				cl.SetLevelColor(WARNING, ColorGreen, ColorDefault, FontDefault)
+				_ = l.LevelColor(ERROR)
+			}
+		}()
+	}
+	wg.Wait()
+	c.Check(l.LevelColor(ERROR).GetForeground(), Equals, ColorRed)
+	c.Check(l.LevelColor(WARNING).GetForeground(), Equals, ColorYellow)
+}
+
+type chunkWriter struct {
+	lock sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	// write one byte at a time so an unserialized writer would interleave
+	for _, b := range data {
+		w.lock.Lock()
+		w.buf.WriteByte(b)
+		w.lock.Unlock()
+	}
+	return len(data), nil
+}
+
+func (a *ConcurrencySuite) TestWritesNotInterleaved(c *C) {
+	w := &chunkWriter{buf: bytes.NewBuffer([]byte{})}
+	l := NewLogger(w, DEBUG)
+	l.SetFlags(0)
+	wg := &sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			msg := strings.Repeat(strconv.Itoa(g), 40)
+			for i := 0; i < 50; i++ {
+				l.RawLogSync(nil, INFO, msg)
+				l.Info(msg)
+			}
+		}(g)
+	}
+	wg.Wait()
+	l.Flush()
+	lines := strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+	c.Check(lines, HasLen, 800)
+	for _, line := range lines {
+		msg := strings.TrimPrefix(line, "INFO     ")
+		ok := len(msg) == 40 && msg == strings.Repeat(msg[:1], 40)
+		c.Check(ok, Equals, true, Commentf("interleaved line %q", line))
+	}
+}
+
+func (a *ConcurrencySuite) TestLayoutColorsWhileLogging(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	layout := NewLayoutFormatter("%{level} %{message}")
+	l := NewLogger(buf, DEBUG).WithColor().WithFormatter(layout)
+	colors := []ColorCode{ColorRed, ColorGreen, ColorBlue}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			layout.SetVerbColor("message", colors[i % len(colors)], ColorDefault, FontDefault)
+		}
+	}()
+	for i := 0; i < 2000; i++ {
+		l.Info(i)
+	}
+	<-done
+	l.Flush()
+	c.Check(layout.VerbColor("message"), NotNil)
+	c.Check(strings.Count(buf.String(), "\n"), Equals, 2000)
+}
+
+func (a *ConcurrencySuite) TestLogfmtColorsWhileLogging(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	logfmt := NewLogfmtFormatter()
+	l := NewLogger(buf, DEBUG).WithColor().WithFormatter(logfmt)
+	colors := []ColorCode{ColorRed, ColorGreen, ColorBlue}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			logfmt.SetKeyColor(colors[i % len(colors)], ColorDefault, FontDefault)
+		}
+	}()
+	for i := 0; i < 2000; i++ {
+		l.Info(i)
+	}
+	<-done
+	l.Flush()
+	c.Check(logfmt.KeyColor(), NotNil)
+	c.Check(strings.Count(buf.String(), "\n"), Equals, 2000)
+}
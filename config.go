@@ -0,0 +1,285 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+type ColorConfig struct {
+	Foreground ColorCode `json:"foreground,omitempty" yaml:"foreground,omitempty"`
+	Background ColorCode `json:"background,omitempty" yaml:"background,omitempty"`
+	Font FontCode `json:"font,omitempty" yaml:"font,omitempty"`
+}
+
+func (cc *ColorConfig) colors() (ColorCode, ColorCode, FontCode) {
+	fg, bg := cc.Foreground, cc.Background
+	if fg == "" {
+		fg = ColorDefault
+	}
+	if bg == "" {
+		bg = ColorDefault
+	}
+	return fg, bg, cc.Font
+}
+
+// OutputConfig describes a destination for log messages.  Type is one of
+// "stderr" (the default), "stdout", "file" (a ReopenFile) or "rotate" (a
+// RotatingFile).  Level, Format, Layout and Colorize default to the
+// top-level settings of the Config.
+type OutputConfig struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	Layout string `json:"layout,omitempty" yaml:"layout,omitempty"`
+	Colorize *bool `json:"colorize,omitempty" yaml:"colorize,omitempty"`
+	MaxSize int64 `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Compress bool `json:"compress,omitempty" yaml:"compress,omitempty"`
+	MaxAge string `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+}
+
+// Config describes a Logger.  The first of Outputs is the logger's primary
+// output; any others are attached as sinks.  With no outputs, the logger
+// writes to stderr.
+type Config struct {
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	Colorize bool `json:"colorize,omitempty" yaml:"colorize,omitempty"`
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	Layout string `json:"layout,omitempty" yaml:"layout,omitempty"`
+	TimeFormat string `json:"time_format,omitempty" yaml:"time_format,omitempty"`
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone,omitempty"`
+	SourceFormat *string `json:"source_format,omitempty" yaml:"source_format,omitempty"`
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Modules string `json:"modules,omitempty" yaml:"modules,omitempty"`
+	LevelColors map[string]ColorConfig `json:"level_colors,omitempty" yaml:"level_colors,omitempty"`
+	TimeColor *ColorConfig `json:"time_color,omitempty" yaml:"time_color,omitempty"`
+	SourceColor *ColorConfig `json:"source_color,omitempty" yaml:"source_color,omitempty"`
+	PrefixColor *ColorConfig `json:"prefix_color,omitempty" yaml:"prefix_color,omitempty"`
+	MessageColor *ColorConfig `json:"message_color,omitempty" yaml:"message_color,omitempty"`
+	FieldColor *ColorConfig `json:"field_color,omitempty" yaml:"field_color,omitempty"`
+	Outputs []OutputConfig `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// LoadConfig reads a JSON or YAML config file, chosen by its extension,
+// and overlays it with settings from the environment.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read log config %s", filename)
+	}
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, cfg)
+	default:
+		err = json.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse log config %s", filename)
+	}
+	cfg.ApplyEnv()
+	return cfg, nil
+}
+
+// ApplyEnv overrides settings from the LOG_LEVEL, LOG_FORMAT and
+// LOG_MODULES environment variables.  A non-empty NO_COLOR disables
+// colorized output everywhere.
+func (cfg *Config) ApplyEnv() {
+	if s, ok := os.LookupEnv("LOG_LEVEL"); ok && s != "" {
+		cfg.Level = s
+	}
+	if s, ok := os.LookupEnv("LOG_FORMAT"); ok && s != "" {
+		cfg.Format = s
+		cfg.Layout = ""
+	}
+	if s, ok := os.LookupEnv("LOG_MODULES"); ok {
+		cfg.Modules = s
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		cfg.Colorize = false
+		for i := range cfg.Outputs {
+			off := false
+			cfg.Outputs[i].Colorize = &off
+		}
+	}
+}
+
+func parseConfigLevel(s string, def LogLevel) (LogLevel, error) {
+	if s == "" {
+		return def, nil
+	}
+	return ParseLevel(s)
+}
+
+func configFormatter(format, layout string) (Formatter, error) {
+	if layout != "" {
+		return NewLayoutFormatter(layout), nil
+	}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return NewTextFormatter(), nil
+	case "json":
+		return NewJSONFormatter(), nil
+	case "logfmt":
+		return NewLogfmtFormatter(), nil
+	}
+	return nil, errors.Errorf("unknown log format %s", format)
+}
+
+// open creates the output's writer.  Rotating files change over on the
+// hour or day in timeZone.
+func (oc *OutputConfig) open(timeZone *time.Location) (io.Writer, error) {
+	switch strings.ToLower(oc.Type) {
+	case "", "stderr":
+		return os.Stderr, nil
+	case "stdout":
+		return os.Stdout, nil
+	case "file":
+		return NewReopenFile(oc.Path)
+	case "rotate":
+		var schedule RotateSchedule
+		switch strings.ToLower(oc.Schedule) {
+		case "", "never":
+			schedule = RotateNever
+		case "hourly":
+			schedule = RotateHourly
+		case "daily":
+			schedule = RotateDaily
+		default:
+			return nil, errors.Errorf("unknown rotation schedule %s", oc.Schedule)
+		}
+		var maxAge time.Duration
+		if oc.MaxAge != "" {
+			var err error
+			maxAge, err = time.ParseDuration(oc.MaxAge)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad max age %s", oc.MaxAge)
+			}
+		}
+		rf, err := NewRotatingFile(oc.Path)
+		if err != nil {
+			return nil, err
+		}
+		rf.SetMaxSize(oc.MaxSize)
+		rf.SetSchedule(schedule, timeZone)
+		rf.SetCompress(oc.Compress)
+		rf.SetMaxAge(maxAge)
+		rf.SetMaxBackups(oc.MaxBackups)
+		return rf, nil
+	}
+	return nil, errors.Errorf("unknown log output type %s", oc.Type)
+}
+
+// NewLoggerFromConfig creates a logger from cfg.  Any settings on the
+// primary output override the corresponding top-level settings.
+func NewLoggerFromConfig(cfg *Config) (*Logger, error) {
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []OutputConfig{{Type: "stderr"}}
+	}
+	level, err := parseConfigLevel(cfg.Level, WARNING)
+	if err != nil {
+		return nil, err
+	}
+	type outputSettings struct {
+		level LogLevel
+		formatter Formatter
+		colorize bool
+	}
+	settings := make([]outputSettings, len(outputs))
+	for i, oc := range outputs {
+		slevel, err := parseConfigLevel(oc.Level, level)
+		if err != nil {
+			return nil, err
+		}
+		format, layout := oc.Format, oc.Layout
+		if format == "" && layout == "" {
+			format, layout = cfg.Format, cfg.Layout
+		}
+		formatter, err := configFormatter(format, layout)
+		if err != nil {
+			return nil, err
+		}
+		colorize := cfg.Colorize
+		if oc.Colorize != nil {
+			colorize = *oc.Colorize
+		}
+		settings[i] = outputSettings{slevel, formatter, colorize}
+	}
+	l := NewLogger(nil, settings[0].level)
+	l.SetFormatter(settings[0].formatter)
+	if settings[0].colorize {
+		l.Colorize()
+	}
+	if cfg.TimeFormat != "" {
+		l.SetTimeFormat(cfg.TimeFormat)
+	}
+	if cfg.TimeZone != "" {
+		tz, err := time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unknown time zone %s", cfg.TimeZone)
+		}
+		l.SetTimeZone(tz)
+	}
+	if cfg.SourceFormat != nil {
+		l.SetSourceFormat(*cfg.SourceFormat)
+	}
+	l.SetPrefix(cfg.Prefix)
+	for name, cc := range cfg.LevelColors {
+		lvl, err := parseConfigLevel(name, NONE)
+		if err != nil {
+			return nil, err
+		}
+		fg, bg, font := cc.colors()
+		l.SetLevelColor(lvl, fg, bg, font)
+	}
+	if cfg.TimeColor != nil {
+		l.SetTimeColor(cfg.TimeColor.colors())
+	}
+	if cfg.SourceColor != nil {
+		l.SetSourceColor(cfg.SourceColor.colors())
+	}
+	if cfg.PrefixColor != nil {
+		l.SetPrefixColor(cfg.PrefixColor.colors())
+	}
+	if cfg.MessageColor != nil {
+		l.SetMessageColor(cfg.MessageColor.colors())
+	}
+	if cfg.FieldColor != nil {
+		l.SetFieldColor(cfg.FieldColor.colors())
+	}
+	err = l.SetModuleLevels(cfg.Modules)
+	if err != nil {
+		return nil, err
+	}
+	opened := []io.Writer{}
+	for i := range outputs {
+		w, err := outputs[i].open(l.TimeZone())
+		if err != nil {
+			for _, ow := range opened {
+				if cl, ok := ow.(io.Closer); ok && ow != os.Stdout && ow != os.Stderr {
+					cl.Close()
+				}
+			}
+			return nil, err
+		}
+		opened = append(opened, w)
+		if i == 0 {
+			l.SetOutput(w)
+		} else {
+			s := settings[i]
+			l.AddSink(NewWriterSink(w, s.level, s.formatter, s.colorize))
+		}
+	}
+	return l, nil
+}
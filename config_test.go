@@ -0,0 +1,212 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConfigSuite struct {
+	env map[string]*string
+}
+var _ = Suite(&ConfigSuite{})
+
+var configEnvVars = []string{"LOG_LEVEL", "LOG_FORMAT", "LOG_MODULES", "NO_COLOR"}
+
+func (a *ConfigSuite) SetUpTest(c *C) {
+	a.env = map[string]*string{}
+	for _, k := range configEnvVars {
+		if v, ok := os.LookupEnv(k); ok {
+			a.env[k] = &v
+		} else {
+			a.env[k] = nil
+		}
+		os.Unsetenv(k)
+	}
+}
+
+func (a *ConfigSuite) TearDownTest(c *C) {
+	for k, v := range a.env {
+		if v == nil {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, *v)
+		}
+	}
+}
+
+// closeOutputs closes any files a config opened, so that they don't linger
+// in the ReopenAll registry
+func closeOutputs(l *Logger) {
+	ws := []interface{}{l.Writer()}
+	for _, s := range l.Sinks() {
+		ws = append(ws, s.(*WriterSink).Writer())
+	}
+	for _, w := range ws {
+		if cl, ok := w.(io.Closer); ok && w != os.Stdout && w != os.Stderr {
+			cl.Close()
+		}
+	}
+}
+
+func writeConfig(c *C, name, data string) string {
+	fn := filepath.Join(c.MkDir(), name)
+	c.Assert(ioutil.WriteFile(fn, []byte(data), 0644), IsNil)
+	return fn
+}
+
+func (a *ConfigSuite) TestJSON(c *C) {
+	dir := c.MkDir()
+	mainLog := filepath.Join(dir, "main.log")
+	jsonLog := filepath.Join(dir, "json.log")
+	data, _ := json.Marshal(map[string]interface{}{
+		"level": "info",
+		"colorize": true,
+		"time_format": "15:04",
+		"time_zone": "UTC",
+		"source_format": "",
+		"prefix": "[app]",
+		"level_colors": map[string]interface{}{
+			"INFO": map[string]string{"foreground": "hot-pink", "font": "bold|underline"},
+		},
+		"prefix_color": map[string]string{"foreground": "green"},
+		"outputs": []map[string]interface{}{
+			{"type": "file", "path": mainLog},
+			{"type": "rotate", "path": jsonLog, "level": "DEBUG", "format": "json", "max_size": 1024},
+		},
+	})
+	cfg, err := LoadConfig(writeConfig(c, "log.json", string(data)))
+	c.Assert(err, IsNil)
+	c.Check(cfg.LevelColors["INFO"], DeepEquals, ColorConfig{Foreground: ColorHotPink, Font: FontBold | FontUnderline})
+	l, err := NewLoggerFromConfig(cfg)
+	c.Assert(err, IsNil)
+	defer closeOutputs(l)
+	c.Check(l.Level(), Equals, INFO)
+	c.Check(l.Prefix(), Equals, "[app]")
+	c.Check(l.TimeZone(), Equals, time.UTC)
+	c.Check(l.Sinks(), HasLen, 1)
+	l.Debugln("debug")
+	l.Infoln("info")
+	l.Flush()
+	c.Check(readFile(c, mainLog), Matches, `.*\d\d:\d\d.* \033\[38;5;199;49;1;4mINFO    \033\[0m \033\[32;49m\[app\]\033\[0m .*info.*\n`)
+	lines := strings.Split(strings.TrimSpace(readFile(c, jsonLog)), "\n")
+	c.Assert(lines, HasLen, 2)
+	obj := map[string]interface{}{}
+	c.Check(json.Unmarshal([]byte(lines[0]), &obj), IsNil)
+	c.Check(obj["msg"], Equals, "debug")
+	c.Check(obj["prefix"], Equals, "[app]")
+}
+
+func (a *ConfigSuite) TestYAML(c *C) {
+	dir := c.MkDir()
+	mainLog := filepath.Join(dir, "main.log")
+	fn := writeConfig(c, "log.yaml", `
+level: error
+format: logfmt
+modules: "config_test.go=INFO"
+outputs:
+  - type: file
+    path: ` + mainLog + `
+    colorize: false
+message_color:
+  foreground: red
+`)
+	cfg, err := LoadConfig(fn)
+	c.Assert(err, IsNil)
+	c.Check(cfg.Level, Equals, "error")
+	c.Check(cfg.MessageColor, DeepEquals, &ColorConfig{Foreground: ColorRed})
+	l, err := NewLoggerFromConfig(cfg)
+	c.Assert(err, IsNil)
+	defer closeOutputs(l)
+	c.Check(l.Level(), Equals, ERROR)
+	c.Check(l.ModuleLevels(), Equals, "config_test.go=INFO")
+	l.Infoln("info")
+	l.Flush()
+	c.Check(readFile(c, mainLog), Matches, `ts=\S+ level=INFO caller=config_test.go:\d+ msg=info\n`)
+	_, err = LoadConfig(writeConfig(c, "log.yml", "leve: INFO\n"))
+	c.Check(err, ErrorMatches, `(?s)can't parse log config .*field leve not found.*`)
+}
+
+func (a *ConfigSuite) TestEnv(c *C) {
+	fn := writeConfig(c, "log.json", `{"level":"INFO","colorize":true,"layout":"%{message}","outputs":[{"type":"stdout"},{"type":"stderr","colorize":true}]}`)
+	os.Setenv("LOG_LEVEL", "debug")
+	os.Setenv("LOG_FORMAT", "json")
+	os.Setenv("LOG_MODULES", "db/*=ERROR")
+	os.Setenv("NO_COLOR", "1")
+	cfg, err := LoadConfig(fn)
+	c.Assert(err, IsNil)
+	c.Check(cfg.Level, Equals, "debug")
+	c.Check(cfg.Format, Equals, "json")
+	c.Check(cfg.Layout, Equals, "")
+	c.Check(cfg.Modules, Equals, "db/*=ERROR")
+	c.Check(cfg.Colorize, Equals, false)
+	c.Check(*cfg.Outputs[1].Colorize, Equals, false)
+	l, err := NewLoggerFromConfig(cfg)
+	c.Assert(err, IsNil)
+	c.Check(l.Level(), Equals, DEBUG)
+	c.Check(l.Writer(), Equals, os.Stdout)
+	_, ok := l.cfg().formatter.(*JSONFormatter)
+	c.Check(ok, Equals, true)
+	c.Check(l.cfg().colorize, Equals, false)
+	c.Check(l.Sinks()[0].(*WriterSink).Colorize(), Equals, false)
+}
+
+func (a *ConfigSuite) TestRotateTimeZone(c *C) {
+	fn := filepath.Join(c.MkDir(), "app.log")
+	l, err := NewLoggerFromConfig(&Config{
+		TimeZone: "Asia/Kolkata",
+		Outputs: []OutputConfig{{Type: "rotate", Path: fn, Schedule: "daily"}},
+	})
+	c.Assert(err, IsNil)
+	defer closeOutputs(l)
+	rf := l.Writer().(*RotatingFile)
+	c.Check(rf.Schedule(), Equals, RotateDaily)
+	c.Check(rf.timeZone, Equals, l.TimeZone())
+	c.Check(rf.timeZone.String(), Equals, "Asia/Kolkata")
+}
+
+func (a *ConfigSuite) TestCloseOnError(c *C) {
+	dir := c.MkDir()
+	blocker := filepath.Join(dir, "blocker")
+	c.Assert(ioutil.WriteFile(blocker, []byte{}, 0644), IsNil)
+	before := len(reopenFiles())
+	_, err := NewLoggerFromConfig(&Config{Outputs: []OutputConfig{
+		{Type: "file", Path: filepath.Join(dir, "main.log")},
+		{Type: "rotate", Path: filepath.Join(blocker, "app.log")},
+	}})
+	c.Check(err, ErrorMatches, `can't create log directory .*`)
+	c.Check(reopenFiles(), HasLen, before)
+}
+
+func (a *ConfigSuite) TestDefaults(c *C) {
+	l, err := NewLoggerFromConfig(&Config{})
+	c.Assert(err, IsNil)
+	c.Check(l.Level(), Equals, WARNING)
+	c.Check(l.Writer(), Equals, os.Stderr)
+	c.Check(l.Sinks(), HasLen, 0)
+}
+
+func (a *ConfigSuite) TestErrors(c *C) {
+	_, err := LoadConfig(filepath.Join(c.MkDir(), "missing.json"))
+	c.Check(err, ErrorMatches, `can't read log config .*`)
+	_, err = LoadConfig(writeConfig(c, "log.json", `{"level_colors":{"INFO":{"foreground":"fuschia"}}}`))
+	c.Check(err, ErrorMatches, `can't parse log config .*unknown color 'fuschia'`)
+	_, err = LoadConfig(writeConfig(c, "log.json", `{"time_color":{"font":"bold|wavy"}}`))
+	c.Check(err, ErrorMatches, `can't parse log config .*unknown font 'wavy'`)
+	_, err = NewLoggerFromConfig(&Config{Level: "LOUD"})
+	c.Check(err, ErrorMatches, `unknown log level LOUD`)
+	_, err = NewLoggerFromConfig(&Config{Format: "xml"})
+	c.Check(err, ErrorMatches, `unknown log format xml`)
+	_, err = NewLoggerFromConfig(&Config{TimeZone: "Mars/Olympus_Mons"})
+	c.Check(err, ErrorMatches, `unknown time zone Mars/Olympus_Mons.*`)
+	_, err = NewLoggerFromConfig(&Config{Outputs: []OutputConfig{{Type: "carrier-pigeon"}}})
+	c.Check(err, ErrorMatches, `unknown log output type carrier-pigeon`)
+	_, err = NewLoggerFromConfig(&Config{Outputs: []OutputConfig{{Type: "rotate", Path: "x.log", Schedule: "weekly"}}})
+	c.Check(err, ErrorMatches, `unknown rotation schedule weekly`)
+}
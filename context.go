@@ -54,3 +54,10 @@ func deepen(ctx context.Context) context.Context {
 func Deepen(ctx context.Context) context.Context {
 	return deepen(ctx)
 }
+
+// WithDepth returns a context that makes log calls report the caller depth
+// frames further up the stack than the function calling the logger, for
+// use by logging helpers and adapters.
+func WithDepth(ctx context.Context, depth int) context.Context {
+	return withDepth(ctx, depth)
+}
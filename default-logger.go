@@ -18,6 +18,14 @@ func Writer() io.Writer {
 	return defaultLogger.Writer()
 }
 
+func SetFormatter(f Formatter) {
+	defaultLogger.SetFormatter(f)
+}
+
+func SetLayout(layout string) {
+	defaultLogger.SetLayout(layout)
+}
+
 func SetLevel(level LogLevel) {
 	defaultLogger.SetLevel(level)
 }
@@ -26,6 +34,22 @@ func Level() LogLevel {
 	return defaultLogger.Level()
 }
 
+func AddSink(s Sink) {
+	defaultLogger.AddSink(s)
+}
+
+func RemoveSink(s Sink) {
+	defaultLogger.RemoveSink(s)
+}
+
+func SetModuleLevels(spec string) error {
+	return defaultLogger.SetModuleLevels(spec)
+}
+
+func ModuleLevels() string {
+	return defaultLogger.ModuleLevels()
+}
+
 func SetLevelColor(level LogLevel, fg, bg ColorCode, font FontCode) {
 	defaultLogger.SetLevelColor(level, fg, bg, font)
 }
@@ -90,10 +114,34 @@ func MessageColor() *Colorizer {
 	return defaultLogger.MessageColor()
 }
 
+func SetFieldColor(fg, bg ColorCode, font FontCode) {
+	defaultLogger.SetFieldColor(fg, bg, font)
+}
+
+func FieldColor() *Colorizer {
+	return defaultLogger.FieldColor()
+}
+
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithField(key, value))
+}
+
+func WithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithFields(fields))
+}
+
 func SetFlags(flags int) {
 	defaultLogger.SetFlags(flags)
 }
 
+func Flush() {
+	defaultLogger.Flush()
+}
+
+func Close() {
+	defaultLogger.Close()
+}
+
 func RawLogSync(ctx context.Context, level LogLevel, args ...interface{}) {
 	l := FromContext(ctx)
 	l.RawLogSync(deepen(ctx), level, args...)
@@ -136,6 +184,18 @@ func Printf(ctx context.Context, format string, args ...interface{}) {
 	RawLogf(deepen(ctx), NONE, format, args...)
 }
 
+func Log(ctx context.Context, level LogLevel, args ...interface{}) {
+	RawLog(deepen(ctx), level, args...)
+}
+
+func Logln(ctx context.Context, level LogLevel, args ...interface{}) {
+	RawLogln(deepen(ctx), level, args...)
+}
+
+func Logf(ctx context.Context, level LogLevel, format string, args ...interface{}) {
+	RawLogf(deepen(ctx), level, format, args...)
+}
+
 func Debug(ctx context.Context, args ...interface{}) {
 	RawLog(deepen(ctx), DEBUG, args...)
 }
@@ -186,31 +246,37 @@ func Errorf(ctx context.Context, format string, args ...interface{}) {
 
 func Fatal(ctx context.Context, args ...interface{}) {
 	RawLogSync(deepen(ctx), CRITICAL, args...)
+	FromContext(ctx).Flush()
 	exiter(1)
 }
 
 func Fatalln(ctx context.Context, args ...interface{}) {
 	RawLoglnSync(deepen(ctx), CRITICAL, args...)
+	FromContext(ctx).Flush()
 	exiter(1)
 }
 
 func Fatalf(ctx context.Context, format string, args ...interface{}) {
 	RawLogfSync(deepen(ctx), CRITICAL, format, args...)
+	FromContext(ctx).Flush()
 	exiter(1)
 }
 
 func Panic(ctx context.Context, args ...interface{}) {
 	RawLogSync(deepen(ctx), CRITICAL, args...)
+	FromContext(ctx).Flush()
 	panic(fmt.Sprint(args...))
 }
 
 func Panicln(ctx context.Context, args ...interface{}) {
 	RawLoglnSync(deepen(ctx), CRITICAL, args...)
+	FromContext(ctx).Flush()
 	panic(fmt.Sprintln(args...))
 }
 
 func Panicf(ctx context.Context, format string, args ...interface{}) {
 	RawLogfSync(deepen(ctx), CRITICAL, format, args...)
+	FromContext(ctx).Flush()
 	panic(fmt.Sprintf(format, args...))
 }
 
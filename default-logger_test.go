@@ -106,6 +106,18 @@ func (a *DefaultSuite) TestPrintf(c *C) {
 	c.Check(strings.TrimSpace(string(buf.Bytes())), Matches, "^[0-9]{4}/[0-9]{2}/[0-9]{2}          unittest default-logger_test.go:[0-9]+: ab / cd$")
 }
 
+func (a *DefaultSuite) TestLog(c *C) {
+	buf := NewBuffer()
+	SetOutput(buf)
+	SetLevel(INFO)
+	SetFlags(log.Ldate | log.Lshortfile)
+	SetPrefix("unittest")
+	Log(nil, DEBUG, "hidden")
+	Logf(nil, WARNING, "%s / %s", "ab", "cd")
+	buf.Wait()
+	c.Check(strings.TrimSpace(string(buf.Bytes())), Matches, "^[0-9]{4}/[0-9]{2}/[0-9]{2} WARNING  unittest default-logger_test.go:[0-9]+: ab / cd$")
+}
+
 func (a *DefaultSuite) TestDebug(c *C) {
 	buf := NewBuffer()
 	SetOutput(buf)
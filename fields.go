@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Field struct {
+	Key string
+	Value interface{}
+}
+
+type Fields []Field
+
+func (f Field) ValueString() string {
+	switch v := f.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(f.Value)
+}
+
+func (f Field) String() string {
+	s := f.ValueString()
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		s = strconv.Quote(s)
+	}
+	return f.Key + "=" + s
+}
+
+func (f Field) jsonValue() interface{} {
+	switch v := f.Value.(type) {
+	case error:
+		return v.Error()
+	case json.Marshaler:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	return f.Value
+}
+
+func (fs Fields) Get(key string) (interface{}, bool) {
+	for i := len(fs) - 1; i >= 0; i-- {
+		if fs[i].Key == key {
+			return fs[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+func (fs Fields) with(more ...Field) Fields {
+	out := make(Fields, 0, len(fs) + len(more))
+	out = append(out, fs...)
+	for _, f := range more {
+		replaced := false
+		for i := range out {
+			if out[i].Key == f.Key {
+				out[i] = f
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (fs Fields) String() string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+func (fs Fields) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{'{'})
+	for i, f := range fs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(f.jsonValue())
+		if err != nil {
+			val, _ = json.Marshal(f.ValueString())
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func fieldsFromMap(m map[string]interface{}) Fields {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fs := make(Fields, len(keys))
+	for i, k := range keys {
+		fs[i] = Field{Key: k, Value: m[k]}
+	}
+	return fs
+}
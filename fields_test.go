@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+type FieldsSuite struct {}
+var _ = Suite(&FieldsSuite{})
+
+func (a *FieldsSuite) TestFieldString(c *C) {
+	c.Check(Field{Key: "user", Value: "bob"}.String(), Equals, `user=bob`)
+	c.Check(Field{Key: "n", Value: 42}.String(), Equals, `n=42`)
+	c.Check(Field{Key: "msg", Value: "two words"}.String(), Equals, `msg="two words"`)
+	c.Check(Field{Key: "empty", Value: ""}.String(), Equals, `empty=""`)
+	c.Check(Field{Key: "err", Value: errors.New("oops")}.String(), Equals, `err=oops`)
+}
+
+func (a *FieldsSuite) TestWithField(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l1 := l.WithField("request", "abc123")
+	l2 := l1.WithFields(map[string]interface{}{"user": 7, "tenant": "acme corp"})
+	l3 := l2.WithField("request", "def456")
+	c.Check(l.Fields(), HasLen, 0)
+	c.Check(l1.Fields(), DeepEquals, Fields{{"request", "abc123"}})
+	c.Check(l2.Fields(), DeepEquals, Fields{{"request", "abc123"}, {"tenant", "acme corp"}, {"user", 7}})
+	c.Check(l3.Fields(), DeepEquals, Fields{{"request", "def456"}, {"tenant", "acme corp"}, {"user", 7}})
+	v, ok := l3.Fields().Get("user")
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, 7)
+	_, ok = l3.Fields().Get("missing")
+	c.Check(ok, Equals, false)
+}
+
+func (a *FieldsSuite) TestTextOutput(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(log.Lshortfile)
+	l = l.WithField("request", "abc123").WithField("user", 7)
+	l.RawLogSync(nil, INFO, "hello")
+	c.Check(strings.TrimSpace(buf.String()), Matches, `^INFO     fields_test.go:[0-9]+: hello request=abc123 user=7$`)
+	buf.Reset()
+	l = l.WithColor()
+	l.SetFieldColor(ColorGreen, ColorDefault, FontDefault)
+	l.RawLogSync(nil, INFO, "hello")
+	c.Check(strings.TrimSpace(buf.String()), Matches, `^.*hello.\[0m .\[32;49mrequest=abc123.\[0m .\[32;49muser=7.\[0m$`)
+}
+
+func (a *FieldsSuite) TestJSON(c *C) {
+	fs := Fields{{"b", 1}, {"a", "x"}, {"err", errors.New("oops")}}
+	data, err := json.Marshal(fs)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `{"b":1,"a":"x","err":"oops"}`)
+}
+
+func (a *FieldsSuite) TestContext(c *C) {
+	buf := NewBuffer()
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	ctx := NewContext(context.Background(), l)
+	ctx = WithField(ctx, "request", "abc123")
+	ctx = WithFields(ctx, map[string]interface{}{"user": 7})
+	Info(ctx, "hello")
+	buf.Wait()
+	c.Check(strings.TrimSpace(string(buf.Bytes())), Equals, `INFO     hello request=abc123 user=7`)
+	c.Check(l.Fields(), HasLen, 0)
+}
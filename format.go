@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TraceInfo describes a span.  TraceID is empty for spans parsed from the
+// legacy trace string argument.
+type TraceInfo struct {
+	TraceID string
+	ParentID string
+	ID string
+	Duration time.Duration
+}
+
+func parseTraceInfo(trace string) *TraceInfo {
+	parts := strings.Fields(trace)
+	if len(parts) == 3 && strings.HasSuffix(parts[2], "s") {
+		secs, err := strconv.ParseFloat(strings.TrimSuffix(parts[2], "s"), 64)
+		if err == nil {
+			return &TraceInfo{
+				ParentID: parts[0],
+				ID: parts[1],
+				Duration: time.Duration(secs * float64(time.Second)),
+			}
+		}
+	}
+	return &TraceInfo{ID: trace}
+}
+
+func (ti *TraceInfo) String() string {
+	if ti.TraceID != "" {
+		parentId := ti.ParentID
+		if parentId == "" {
+			parentId = "-"
+		}
+		s := ti.TraceID + " " + parentId + " " + ti.ID
+		if ti.Duration != 0 {
+			s += fmt.Sprintf(" %09.6fs", ti.Duration.Seconds())
+		}
+		return s
+	}
+	if ti.Duration == 0 {
+		if ti.ParentID == "" {
+			return ti.ID
+		}
+		return ti.ParentID + " " + ti.ID
+	}
+	return fmt.Sprintf("%s %s %09.6fs", ti.ParentID, ti.ID, ti.Duration.Seconds())
+}
+
+// ids lists the span's IDs as trace_parent, trace_id and span_id fields
+func (ti *TraceInfo) ids() Fields {
+	fs := Fields{}
+	if ti.ParentID != "" {
+		fs = append(fs, Field{Key: "trace_parent", Value: ti.ParentID})
+	}
+	if ti.TraceID == "" {
+		return append(fs, Field{Key: "trace_id", Value: ti.ID})
+	}
+	return append(fs, Field{Key: "trace_id", Value: ti.TraceID}, Field{Key: "span_id", Value: ti.ID})
+}
+
+type Record struct {
+	Logger *Logger
+	Time time.Time
+	Level LogLevel
+	Prefix string
+	Trace *TraceInfo
+	Source *SourceRecord
+	Message string
+	Fields Fields
+	Goroutine uint64
+	Colorize bool
+	config *loggerConfig
+	levelOverride bool
+	overrideLevel LogLevel
+}
+
+func (l *Logger) NewRecord(ctx context.Context, level LogLevel, sr *SourceRecord, message string) *Record {
+	cfg := l.cfg()
+	t := time.Now()
+	if cfg.timeZone != nil {
+		t = t.In(cfg.timeZone)
+	}
+	rec := &Record{
+		Logger: l,
+		Time: t,
+		Level: level,
+		Prefix: cfg.prefix,
+		Trace: traceInfo(ctx),
+		Source: sr,
+		Message: message,
+		Fields: cfg.fields,
+		Colorize: cfg.colorize,
+		config: cfg,
+	}
+	if cfg.modules != nil && sr != nil {
+		rec.overrideLevel, rec.levelOverride = cfg.modules.forSource(sr)
+	}
+	if gf, ok := cfg.formatter.(interface{ needsGoroutine() bool }); ok && gf.needsGoroutine() {
+		rec.Goroutine = goroutineID()
+	}
+	return rec
+}
+
+func (rec *Record) settings() *loggerConfig {
+	if rec.config != nil {
+		return rec.config
+	}
+	if rec.Logger != nil {
+		return rec.Logger.cfg()
+	}
+	return defaultLogger.cfg()
+}
+
+func (rec *Record) colorizer(defaultColorizer, colorizer *Colorizer) *Colorizer {
+	if !rec.Colorize {
+		return nil
+	}
+	if colorizer != nil {
+		return colorizer
+	}
+	return defaultColorizer
+}
+
+type Formatter interface {
+	Format(rec *Record) ([]byte, error)
+}
+
+type TextFormatter struct {}
+
+func NewTextFormatter() *TextFormatter {
+	return &TextFormatter{}
+}
+
+func (tf *TextFormatter) Format(rec *Record) ([]byte, error) {
+	l := rec.settings()
+	dc := rec.colorizer(l.levelColor[rec.Level], nil)
+	line := ""
+	if l.timeFormat != "" {
+		c := rec.colorizer(dc, l.timeColor)
+		if c != nil {
+			line += c.Colorize(rec.Time.Format(l.timeFormat))
+		} else {
+			line += rec.Time.Format(l.timeFormat)
+		}
+		line += " "
+	}
+	if dc != nil {
+		line += dc.Colorize(rec.Level.PaddedString(0))
+	} else {
+		line += rec.Level.PaddedString(0)
+	}
+	line += " "
+	if rec.Prefix != "" {
+		c := rec.colorizer(dc, l.prefixColor)
+		if c != nil {
+			line += c.Colorize(rec.Prefix)
+		} else {
+			line += rec.Prefix
+		}
+		line += " "
+	}
+	if rec.Trace != nil {
+		if dc != nil {
+			line += dc.Colorize(rec.Trace.String())
+		} else {
+			line += rec.Trace.String()
+		}
+		line += " "
+	}
+	if l.sourceFormat != nil {
+		c := rec.colorizer(dc, l.sourceColor)
+		if c != nil {
+			line += c.Colorize(l.sourceFormat.FormatRecord(rec.Source))
+		} else {
+			line += l.sourceFormat.FormatRecord(rec.Source)
+		}
+		line += " "
+	}
+	c := rec.colorizer(dc, l.messageColor)
+	if c != nil {
+		line += c.Colorize(strings.TrimSpace(rec.Message))
+	} else {
+		line += strings.TrimSpace(rec.Message)
+	}
+	if len(rec.Fields) > 0 {
+		c = rec.colorizer(dc, l.fieldColor)
+		for _, f := range rec.Fields {
+			if c != nil {
+				line += " " + c.Colorize(f.String())
+			} else {
+				line += " " + f.String()
+			}
+		}
+	}
+	line += "\n"
+	return []byte(line), nil
+}
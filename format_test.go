@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type FormatSuite struct {}
+var _ = Suite(&FormatSuite{})
+
+type testFormatter struct {}
+
+func (tf *testFormatter) Format(rec *Record) ([]byte, error) {
+	return []byte(rec.Level.String() + "|" + rec.Prefix + "|" + rec.Source.Function + "|" + rec.Message + "|" + rec.Fields.String() + "\n"), nil
+}
+
+func (a *FormatSuite) TestParseTraceInfo(c *C) {
+	ti := parseTraceInfo("AAAA BBBB 01.500000s")
+	c.Check(ti.ParentID, Equals, "AAAA")
+	c.Check(ti.ID, Equals, "BBBB")
+	c.Check(ti.Duration, Equals, 1500 * time.Millisecond)
+	c.Check(ti.String(), Equals, "AAAA BBBB 01.500000s")
+	ti = parseTraceInfo("whatever")
+	c.Check(ti.ID, Equals, "whatever")
+	c.Check(ti.String(), Equals, "whatever")
+}
+
+func (a *FormatSuite) TestCustomFormatter(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	c.Check(l.Formatter(), FitsTypeOf, &TextFormatter{})
+	l2 := l.WithFormatter(&testFormatter{}).WithPrefix("unittest").WithField("k", "v")
+	c.Check(l.Formatter(), FitsTypeOf, &TextFormatter{})
+	l2.RawLogSync(nil, WARNING, "hello")
+	c.Check(buf.String(), Equals, "WARNING|unittest|TestCustomFormatter|hello|k=v\n")
+	l2.SetFormatter(nil)
+	c.Check(l2.Formatter(), FitsTypeOf, &TextFormatter{})
+}
+
+func (a *FormatSuite) TestTextTrace(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFlags(log.Lshortfile)
+	l.RawWrite(nil, TRACE, "hello", "AAAA BBBB 01.500000s")
+	c.Check(strings.TrimSpace(buf.String()), Matches, `^TRACE    AAAA BBBB 01.500000s format_test.go:[0-9]+: hello$`)
+	buf.Reset()
+	l.Trace(context.Background(), func(ctx context.Context) error { return nil }, "traced")
+	c.Check(strings.TrimSpace(buf.String()), Matches, `^TRACE    [0-9a-f]{32} - [0-9a-f]{16} [0-9.]+s format_test.go:[0-9]+: traced$`)
+}
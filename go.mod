@@ -3,6 +3,8 @@ module github.com/rclancey/logging
 go 1.15
 
 require (
+	github.com/go-logr/logr v1.4.3
 	github.com/pkg/errors v0.9.1
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,198 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+type JournalSink struct {
+	lock sync.Mutex
+	level int32
+	path string
+	identifier string
+	fields Fields
+	conn *net.UnixConn
+}
+
+func NewJournalSink(level LogLevel) (*JournalSink, error) {
+	return NewJournalSinkAt(DefaultJournalSocket, level)
+}
+
+func NewJournalSinkAt(path string, level LogLevel) (*JournalSink, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create journal socket")
+	}
+	return &JournalSink{
+		level: int32(level),
+		path: path,
+		identifier: filepath.Base(os.Args[0]),
+		conn: conn,
+	}, nil
+}
+
+func (s *JournalSink) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&s.level, int32(level))
+}
+
+func (s *JournalSink) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&s.level))
+}
+
+// SetIdentifier sets the SYSLOG_IDENTIFIER used for records that have no
+// prefix.
+func (s *JournalSink) SetIdentifier(identifier string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.identifier = identifier
+}
+
+func (s *JournalSink) Identifier() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.identifier
+}
+
+// SetField adds a field sent with every entry, eg. an application
+// version.
+func (s *JournalSink) SetField(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.fields = s.fields.with(Field{Key: key, Value: value})
+}
+
+func (s *JournalSink) Close() error {
+	return s.conn.Close()
+}
+
+// journalKey converts a field name into the upper-case, alphanumeric form
+// journald requires.  Leading underscores are reserved for trusted fields.
+func journalKey(key string) string {
+	out := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			out = append(out, c - 'a' + 'A')
+		case (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+			out = append(out, c)
+		default:
+			out = append(out, '_')
+		}
+	}
+	s := strings.TrimLeft(string(out), "_0123456789")
+	if len(s) > 64 {
+		s = s[:64]
+	}
+	return s
+}
+
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	key = journalKey(key)
+	if key == "" {
+		return
+	}
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteString(key)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func (s *JournalSink) encode(rec *Record) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	writeJournalField(buf, "MESSAGE", strings.TrimSpace(rec.Message))
+	writeJournalField(buf, "PRIORITY", strconv.Itoa(syslogSeverity(rec.Level)))
+	ident := rec.Prefix
+	if ident == "" {
+		ident = s.identifier
+	}
+	writeJournalField(buf, "SYSLOG_IDENTIFIER", ident)
+	if rec.Source != nil {
+		writeJournalField(buf, "CODE_FILE", rec.Source.FullPath)
+		writeJournalField(buf, "CODE_LINE", strconv.Itoa(rec.Source.LineNumber))
+		writeJournalField(buf, "CODE_FUNC", rec.Source.Package + "." + rec.Source.QualifiedFunction)
+	}
+	if rec.Trace != nil {
+		for _, f := range rec.Trace.ids() {
+			writeJournalField(buf, strings.ToUpper(f.Key), f.ValueString())
+		}
+		if rec.Trace.Duration != 0 {
+			writeJournalField(buf, "TRACE_DURATION", rec.Trace.Duration.String())
+		}
+	}
+	for _, f := range s.fields {
+		writeJournalField(buf, f.Key, f.ValueString())
+	}
+	for _, f := range rec.Fields {
+		writeJournalField(buf, f.Key, f.ValueString())
+	}
+	return buf.Bytes()
+}
+
+func (s *JournalSink) Log(rec *Record) error {
+	if rec.Level > s.Level() {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data := s.encode(rec)
+	addr := &net.UnixAddr{Name: s.path, Net: "unixgram"}
+	_, _, err := s.conn.WriteMsgUnix(data, nil, addr)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return errors.Wrap(err, "can't write to journal")
+	}
+	return s.sendFile(data, addr)
+}
+
+// sendFile passes oversized entries to journald as a file descriptor.
+// memfd_create isn't exposed by syscall on every architecture, so an
+// unlinked temp file, preferably on tmpfs, stands in for it.
+func (s *JournalSink) sendFile(data []byte, addr *net.UnixAddr) error {
+	dir := "/dev/shm"
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		dir = ""
+	}
+	f, err := ioutil.TempFile(dir, "journal.")
+	if err != nil {
+		return errors.Wrap(err, "can't create journal temp file")
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err != nil {
+		return errors.Wrap(err, "can't write journal temp file")
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	_, _, err = s.conn.WriteMsgUnix(nil, rights, addr)
+	if err != nil {
+		return errors.Wrap(err, "can't write to journal")
+	}
+	return nil
+}
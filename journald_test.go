@@ -0,0 +1,120 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type JournalSuite struct {}
+var _ = Suite(&JournalSuite{})
+
+func decodeJournal(c *C, data []byte) map[string]string {
+	out := map[string]string{}
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		c.Assert(i > 0, Equals, true)
+		key := string(data[:i])
+		if data[i] == '=' {
+			j := bytes.IndexByte(data, '\n')
+			out[key] = string(data[i+1:j])
+			data = data[j+1:]
+			continue
+		}
+		n := binary.LittleEndian.Uint64(data[i+1:i+9])
+		out[key] = string(data[i+9:i+9+int(n)])
+		data = data[i+10+int(n):]
+	}
+	return out
+}
+
+func readJournal(c *C, conn *net.UnixConn) map[string]string {
+	buf := make([]byte, 1024 * 1024)
+	oob := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	c.Assert(err, IsNil)
+	if oobn == 0 {
+		return decodeJournal(c, buf[:n])
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	c.Assert(err, IsNil)
+	c.Assert(msgs, HasLen, 1)
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	c.Assert(err, IsNil)
+	c.Assert(fds, HasLen, 1)
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	f.Seek(0, 0)
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	out := decodeJournal(c, data)
+	out["_FD"] = "true"
+	return out
+}
+
+func (a *JournalSuite) TestJournalKey(c *C) {
+	c.Check(journalKey("user_id"), Equals, "USER_ID")
+	c.Check(journalKey("_trusted"), Equals, "TRUSTED")
+	c.Check(journalKey("http.status-code"), Equals, "HTTP_STATUS_CODE")
+	c.Check(journalKey("9lives"), Equals, "LIVES")
+}
+
+func (a *JournalSuite) TestJournal(c *C) {
+	path := filepath.Join(c.MkDir(), "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	c.Assert(err, IsNil)
+	defer server.Close()
+	s, err := NewJournalSinkAt(path, INFO)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	s.SetIdentifier("unittest")
+	c.Check(s.Identifier(), Equals, "unittest")
+	s.SetField("version", "1.2.3")
+	l := NewLogger(nil, NONE).WithSink(s).WithField("user_id", 7)
+	l.RawLogSync(nil, DEBUG, "skipped")
+	l.RawLogSync(nil, WARNING, "line one\nline two\n")
+	entry := readJournal(c, server)
+	c.Check(entry["MESSAGE"], Equals, "line one\nline two")
+	c.Check(entry["PRIORITY"], Equals, "4")
+	c.Check(entry["SYSLOG_IDENTIFIER"], Equals, "unittest")
+	c.Check(entry["CODE_FILE"], Matches, ".*/journald_test.go")
+	c.Check(entry["CODE_LINE"], Matches, "[0-9]+")
+	c.Check(entry["CODE_FUNC"], Equals, "github.com/rclancey/logging.(*JournalSuite).TestJournal")
+	c.Check(entry["VERSION"], Equals, "1.2.3")
+	c.Check(entry["USER_ID"], Equals, "7")
+	l.WithPrefix("db").RawWrite(nil, INFO, "traced", "AAAA BBBB 01.500000s")
+	entry = readJournal(c, server)
+	c.Check(entry["SYSLOG_IDENTIFIER"], Equals, "db")
+	c.Check(entry["PRIORITY"], Equals, "6")
+	c.Check(entry["TRACE_PARENT"], Equals, "AAAA")
+	c.Check(entry["TRACE_ID"], Equals, "BBBB")
+	c.Check(entry["TRACE_DURATION"], Equals, "1.5s")
+}
+
+func (a *JournalSuite) TestOversized(c *C) {
+	path := filepath.Join(c.MkDir(), "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	c.Assert(err, IsNil)
+	defer server.Close()
+	s, err := NewJournalSinkAt(path, DEBUG)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	msg := strings.Repeat("x", 4 * 1024 * 1024)
+	c.Check(s.Log(&Record{Level: ERROR, Message: msg}), IsNil)
+	entry := readJournal(c, server)
+	c.Check(entry["_FD"], Equals, "true")
+	c.Check(entry["MESSAGE"], Equals, msg)
+	c.Check(entry["PRIORITY"], Equals, "3")
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+var jsonReservedKeys = map[string]bool{
+	"time": true,
+	"level": true,
+	"prefix": true,
+	"msg": true,
+	"file": true,
+	"line": true,
+	"function": true,
+	"package": true,
+	"trace_parent": true,
+	"trace_id": true,
+	"span_id": true,
+	"duration": true,
+}
+
+type JSONFormatter struct {}
+
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+type jsonObject struct {
+	buf *bytes.Buffer
+	err error
+}
+
+func (obj *jsonObject) add(key string, value interface{}) {
+	if obj.err != nil {
+		return
+	}
+	k, err := json.Marshal(key)
+	if err != nil {
+		obj.err = err
+		return
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		obj.err = err
+		return
+	}
+	if obj.buf.Len() > 1 {
+		obj.buf.WriteByte(',')
+	}
+	obj.buf.Write(k)
+	obj.buf.WriteByte(':')
+	obj.buf.Write(v)
+}
+
+func (jf *JSONFormatter) Format(rec *Record) ([]byte, error) {
+	obj := &jsonObject{buf: bytes.NewBuffer([]byte{'{'})}
+	obj.add("time", rec.Time.Format(time.RFC3339Nano))
+	obj.add("level", rec.Level)
+	if rec.Prefix != "" {
+		obj.add("prefix", rec.Prefix)
+	}
+	obj.add("msg", strings.TrimSuffix(rec.Message, "\n"))
+	if rec.Source != nil {
+		obj.add("file", rec.Source.FullPath)
+		obj.add("line", rec.Source.LineNumber)
+		obj.add("function", rec.Source.QualifiedFunction)
+		obj.add("package", rec.Source.Package)
+	}
+	if rec.Trace != nil {
+		for _, f := range rec.Trace.ids() {
+			obj.add(f.Key, f.Value)
+		}
+		if rec.Trace.Duration != 0 {
+			obj.add("duration", rec.Trace.Duration.Seconds())
+		}
+	}
+	for _, f := range rec.Fields {
+		key := f.Key
+		if jsonReservedKeys[key] {
+			key = "fields." + key
+		}
+		val := f.jsonValue()
+		if _, err := json.Marshal(val); err != nil {
+			val = f.ValueString()
+		}
+		obj.add(key, val)
+	}
+	if obj.err != nil {
+		return nil, obj.err
+	}
+	obj.buf.WriteString("}\n")
+	return obj.buf.Bytes(), nil
+}
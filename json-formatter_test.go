@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type JSONFormatterSuite struct {}
+var _ = Suite(&JSONFormatterSuite{})
+
+func (a *JSONFormatterSuite) TestFormat(c *C) {
+	tz, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFormatter(NewJSONFormatter())
+	l.SetTimeZone(tz)
+	l.Colorize()
+	l = l.WithPrefix("unittest").WithField("user", 7).WithField("msg", "shadowed")
+	l.RawLogSync(nil, WARNING, "line one\n\"line two\"\n")
+	c.Check(bytes.Count(buf.Bytes(), []byte("\n")), Equals, 1)
+	c.Check(bytes.Contains(buf.Bytes(), []byte("\033")), Equals, false)
+	c.Check(buf.String(), Matches, `^\{"time":"[0-9T:.-]+-0[45]:00","level":"WARNING","prefix":"unittest","msg":"line one\\n\\"line two\\"",.*\n$`)
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(buf.Bytes(), &obj)
+	c.Assert(err, IsNil)
+	c.Check(obj["msg"], Equals, "line one\n\"line two\"")
+	c.Check(obj["file"], Matches, `.*/json-formatter_test\.go`)
+	c.Check(obj["line"], FitsTypeOf, float64(0))
+	c.Check(obj["function"], Equals, "(*JSONFormatterSuite).TestFormat")
+	c.Check(obj["package"], Equals, "github.com/rclancey/logging")
+	c.Check(obj["user"], Equals, float64(7))
+	c.Check(obj["fields.msg"], Equals, "shadowed")
+	_, ok := obj["trace_id"]
+	c.Check(ok, Equals, false)
+}
+
+func (a *JSONFormatterSuite) TestTrace(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFormatter(NewJSONFormatter())
+	l.RawWrite(nil, TRACE, "traced", "AAAA BBBB 01.500000s")
+	obj := map[string]interface{}{}
+	err := json.Unmarshal(buf.Bytes(), &obj)
+	c.Assert(err, IsNil)
+	c.Check(obj["level"], Equals, "TRACE")
+	c.Check(obj["trace_parent"], Equals, "AAAA")
+	c.Check(obj["trace_id"], Equals, "BBBB")
+	c.Check(obj["duration"], Equals, 1.5)
+}
+
+func (a *JSONFormatterSuite) TestTraceFieldCollision(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFormatter(NewJSONFormatter())
+	ctx := NewContext(context.Background(), l.WithField("span_id", "x").WithField("trace_id", "y"))
+	var spanId string
+	l.Trace(ctx, func(ctx context.Context) error {
+		spanId = SpanID(ctx)
+		FromContext(ctx).RawLogSync(ctx, INFO, "inside")
+		return nil
+	}, "traced")
+	l.Flush()
+	line := strings.SplitN(buf.String(), "\n", 2)[0]
+	c.Check(strings.Count(line, `"span_id":`), Equals, 1)
+	obj := map[string]interface{}{}
+	c.Assert(json.Unmarshal([]byte(line), &obj), IsNil)
+	c.Check(obj["span_id"], Equals, spanId)
+	c.Check(obj["fields.span_id"], Equals, "x")
+	c.Check(obj["fields.trace_id"], Equals, "y")
+}
@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var startTime = time.Now()
+
+var hostnameOnce sync.Once
+var hostname string
+
+func getHostname() string {
+	hostnameOnce.Do(func() {
+		h, err := os.Hostname()
+		if err == nil {
+			hostname = h
+		}
+	})
+	return hostname
+}
+
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	i := bytes.IndexByte(buf, ' ')
+	if i < 0 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(buf[:i]), 10, 64)
+	return id
+}
+
+var layoutVerbs = map[string]bool{
+	"time": true,
+	"level": true,
+	"prefix": true,
+	"source": true,
+	"message": true,
+	"fields": true,
+	"pid": true,
+	"hostname": true,
+	"goroutine": true,
+	"elapsed": true,
+	"traceid": true,
+	"spanid": true,
+}
+
+type layoutPart struct {
+	literal string
+	verb string
+	format string
+}
+
+type LayoutFormatter struct {
+	layout string
+	parts []layoutPart
+	// verbColor is replaced rather than modified, so Format can use it
+	// after releasing lock
+	lock sync.RWMutex
+	verbColor map[string]*Colorizer
+	goroutine bool
+}
+
+func NewLayoutFormatter(layout string) *LayoutFormatter {
+	lf := &LayoutFormatter{
+		layout: layout,
+		parts: []layoutPart{},
+		verbColor: map[string]*Colorizer{},
+	}
+	prev := 0
+	for _, m := range fmtre.FindAllStringSubmatchIndex(layout, -1) {
+		start, end := m[0], m[1]
+		if start > prev {
+			lf.parts = append(lf.parts, layoutPart{literal: layout[prev:start]})
+		}
+		prev = end
+		name := layout[m[2]:m[3]]
+		if !layoutVerbs[name] {
+			lf.parts = append(lf.parts, layoutPart{literal: layout[start:end]})
+			continue
+		}
+		part := layoutPart{verb: name, format: "%v"}
+		if m[4] != -1 {
+			part.format = "%" + layout[m[4]:m[5]]
+		}
+		if name == "goroutine" {
+			lf.goroutine = true
+		}
+		lf.parts = append(lf.parts, part)
+	}
+	if prev < len(layout) {
+		lf.parts = append(lf.parts, layoutPart{literal: layout[prev:]})
+	}
+	return lf
+}
+
+func (lf *LayoutFormatter) Layout() string {
+	return lf.layout
+}
+
+func (lf *LayoutFormatter) SetVerbColor(verb string, fg, bg ColorCode, font FontCode) {
+	c, err := NewColorizer(fg, bg, font)
+	if err != nil {
+		return
+	}
+	lf.lock.Lock()
+	defer lf.lock.Unlock()
+	verbColor := make(map[string]*Colorizer, len(lf.verbColor) + 1)
+	for k, v := range lf.verbColor {
+		verbColor[k] = v
+	}
+	verbColor[verb] = c
+	lf.verbColor = verbColor
+}
+
+func (lf *LayoutFormatter) VerbColor(verb string) *Colorizer {
+	return lf.verbColors()[verb]
+}
+
+func (lf *LayoutFormatter) verbColors() map[string]*Colorizer {
+	lf.lock.RLock()
+	defer lf.lock.RUnlock()
+	return lf.verbColor
+}
+
+func (lf *LayoutFormatter) needsGoroutine() bool {
+	return lf.goroutine
+}
+
+func (lf *LayoutFormatter) value(l *loggerConfig, rec *Record, verb string) (interface{}, *Colorizer) {
+	switch verb {
+	case "time":
+		if l.timeFormat == "" {
+			return "", l.timeColor
+		}
+		return rec.Time.Format(l.timeFormat), l.timeColor
+	case "level":
+		return rec.Level.String(), nil
+	case "prefix":
+		return rec.Prefix, l.prefixColor
+	case "source":
+		if l.sourceFormat == nil {
+			return "", l.sourceColor
+		}
+		return l.sourceFormat.FormatRecord(rec.Source), l.sourceColor
+	case "message":
+		return strings.TrimSpace(rec.Message), l.messageColor
+	case "fields":
+		return rec.Fields.String(), l.fieldColor
+	case "pid":
+		return os.Getpid(), nil
+	case "hostname":
+		return getHostname(), nil
+	case "goroutine":
+		return rec.Goroutine, nil
+	case "elapsed":
+		return fmt.Sprintf("%.6f", rec.Time.Sub(startTime).Seconds()), nil
+	case "traceid":
+		if rec.Trace == nil {
+			return "", nil
+		}
+		if rec.Trace.TraceID != "" {
+			return rec.Trace.TraceID, nil
+		}
+		return rec.Trace.ID, nil
+	case "spanid":
+		if rec.Trace == nil || rec.Trace.TraceID == "" {
+			return "", nil
+		}
+		return rec.Trace.ID, nil
+	}
+	return "", nil
+}
+
+func (lf *LayoutFormatter) Format(rec *Record) ([]byte, error) {
+	l := rec.settings()
+	dc := rec.colorizer(l.levelColor[rec.Level], nil)
+	verbColor := lf.verbColors()
+	line := ""
+	for _, part := range lf.parts {
+		if part.verb == "" {
+			line += part.literal
+			continue
+		}
+		val, vc := lf.value(l, rec, part.verb)
+		if override, ok := verbColor[part.verb]; ok {
+			vc = override
+		}
+		s := fmt.Sprintf(part.format, val)
+		c := rec.colorizer(dc, vc)
+		if c != nil {
+			line += c.Colorize(s)
+		} else {
+			line += s
+		}
+	}
+	line += "\n"
+	return []byte(line), nil
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type LayoutSuite struct {}
+var _ = Suite(&LayoutSuite{})
+
+func (a *LayoutSuite) TestParse(c *C) {
+	lf := NewLayoutFormatter("%{time} [%{level:-5s}] %{foo:x} %{message}")
+	c.Check(lf.Layout(), Equals, "%{time} [%{level:-5s}] %{foo:x} %{message}")
+	c.Check(lf.parts, DeepEquals, []layoutPart{
+		{verb: "time", format: "%v"},
+		{literal: " ["},
+		{verb: "level", format: "%-5s"},
+		{literal: "] "},
+		{literal: "%{foo:x}"},
+		{literal: " "},
+		{verb: "message", format: "%v"},
+	})
+	c.Check(lf.needsGoroutine(), Equals, false)
+	c.Check(NewLayoutFormatter("%{goroutine}").needsGoroutine(), Equals, true)
+}
+
+func (a *LayoutSuite) TestFormat(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetTimeFormat("2006")
+	l.SetLayout("%{time} [%{level:-5.5s}] %{prefix} %{source} %{message} %{fields}")
+	l = l.WithPrefix("unittest").WithField("k", "v")
+	l.RawLogSync(nil, WARNING, "hello\n")
+	c.Check(buf.String(), Matches, `^[0-9]{4} \[WARNI\] unittest layout_test\.go:[0-9]+: hello k=v\n$`)
+	buf.Reset()
+	l.RawLogSync(nil, INFO, "hello")
+	c.Check(buf.String(), Matches, `^[0-9]{4} \[INFO \] unittest layout_test\.go:[0-9]+: hello k=v\n$`)
+}
+
+func (a *LayoutSuite) TestProcessVerbs(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetLayout("%{pid:d} %{hostname} %{goroutine} %{elapsed} <%{traceid}> %{message}")
+	l.RawLogSync(nil, INFO, "hello")
+	host, _ := os.Hostname()
+	prefix := fmt.Sprintf("%d %s ", os.Getpid(), host)
+	c.Check(strings.HasPrefix(buf.String(), prefix), Equals, true)
+	c.Check(strings.TrimPrefix(buf.String(), prefix), Matches, `^[1-9][0-9]* [0-9]+\.[0-9]{6} <> hello\n$`)
+	buf.Reset()
+	l.RawWrite(nil, INFO, "traced", "AAAA BBBB 01.500000s")
+	c.Check(strings.TrimPrefix(buf.String(), prefix), Matches, `^[1-9][0-9]* [0-9]+\.[0-9]{6} <BBBB> traced\n$`)
+}
+
+func (a *LayoutSuite) TestColor(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	lf := NewLayoutFormatter("%{level:-5s}|%{prefix}|%{message}")
+	lf.SetVerbColor("message", ColorGreen, ColorDefault, FontDefault)
+	c.Check(lf.VerbColor("message").GetForeground(), Equals, ColorGreen)
+	l.SetFormatter(lf)
+	l.SetPrefix("unittest")
+	l.SetPrefixColor(ColorCyan, ColorDefault, FontDefault)
+	l = l.WithColor()
+	l.RawLogSync(nil, ERROR, "hello")
+	c.Check(buf.String(), Equals, "\033[31;49mERROR\033[0m|\033[36;49munittest\033[0m|\033[32;49mhello\033[0m\n")
+}
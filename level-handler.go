@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LevelHandler is an http.Handler for inspecting and changing a logger's
+// level at runtime.  GET returns the current level; PUT or POST sets it,
+// either from a JSON body like {"level":"DEBUG","ttl":"10m"} or from the
+// level and ttl query parameters.  When a TTL is given, the level that was
+// in effect before the change is restored once it expires.
+type LevelHandler struct {
+	logger *Logger
+	lock sync.Mutex
+	timer *time.Timer
+	restore LogLevel
+	expires time.Time
+}
+
+type levelState struct {
+	Level LogLevel `json:"level"`
+	Restore *LogLevel `json:"restore,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+type levelRequest struct {
+	Level *LogLevel `json:"level"`
+	TTL string `json:"ttl,omitempty"`
+}
+
+// NewLevelHandler creates a handler controlling l, or the default logger
+// if l is nil.
+func NewLevelHandler(l *Logger) *LevelHandler {
+	return &LevelHandler{logger: l}
+}
+
+func (h *LevelHandler) getLogger() *Logger {
+	if h.logger == nil {
+		return defaultLogger
+	}
+	return h.logger
+}
+
+func (h *LevelHandler) state() levelState {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	st := levelState{Level: h.getLogger().Level()}
+	if h.timer != nil {
+		restore := h.restore
+		expires := h.expires
+		st.Restore = &restore
+		st.Expires = &expires
+	}
+	return st
+}
+
+// Set changes the level.  A positive ttl schedules the restoration of the
+// level that was in effect before any pending temporary change; otherwise
+// the change is permanent and any pending restoration is cancelled.
+func (h *LevelHandler) Set(level LogLevel, ttl time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	l := h.getLogger()
+	restore := l.Level()
+	if h.timer != nil {
+		h.timer.Stop()
+		restore = h.restore
+		h.timer = nil
+	}
+	l.SetLevel(level)
+	if ttl <= 0 {
+		return
+	}
+	h.restore = restore
+	h.expires = time.Now().Add(ttl)
+	var t *time.Timer
+	t = time.AfterFunc(ttl, func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		if h.timer != t {
+			return
+		}
+		h.timer = nil
+		l.SetLevel(h.restore)
+	})
+	h.timer = t
+}
+
+func (h *LevelHandler) parseRequest(r *http.Request) (LogLevel, time.Duration, error) {
+	req := levelRequest{}
+	q := r.URL.Query()
+	if s := q.Get("level"); s != "" {
+		level, err := ParseLevel(s)
+		if err != nil {
+			return NONE, 0, err
+		}
+		req.Level = &level
+		req.TTL = q.Get("ttl")
+	} else {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return NONE, 0, errors.Wrap(err, "can't decode level request")
+		}
+		if req.Level == nil {
+			return NONE, 0, errors.New("no level specified")
+		}
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil {
+			return NONE, 0, errors.Wrapf(err, "bad ttl %s", req.TTL)
+		}
+		if ttl < 0 {
+			return NONE, 0, errors.Errorf("negative ttl %s", req.TTL)
+		}
+	}
+	return *req.Level, ttl, nil
+}
+
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		level, ttl, err := h.parseRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.Set(level, ttl)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := json.Marshal(h.state())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
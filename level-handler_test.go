@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type LevelHandlerSuite struct {}
+var _ = Suite(&LevelHandlerSuite{})
+
+func levelRequestTo(c *C, h http.Handler, method, target, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	obj := map[string]interface{}{}
+	if res.Code == http.StatusOK {
+		c.Check(res.Header().Get("Content-Type"), Equals, "application/json")
+		c.Check(json.Unmarshal(res.Body.Bytes(), &obj), IsNil)
+	}
+	return res, obj
+}
+
+func (a *LevelHandlerSuite) TestGetAndSet(c *C) {
+	l := NewLogger(nil, INFO)
+	h := NewLevelHandler(l)
+	res, obj := levelRequestTo(c, h, "GET", "/", "")
+	c.Check(res.Code, Equals, http.StatusOK)
+	c.Check(obj, DeepEquals, map[string]interface{}{"level": "INFO"})
+	res, obj = levelRequestTo(c, h, "PUT", "/", `{"level":"DEBUG"}`)
+	c.Check(res.Code, Equals, http.StatusOK)
+	c.Check(obj, DeepEquals, map[string]interface{}{"level": "DEBUG"})
+	c.Check(l.Level(), Equals, DEBUG)
+	res, obj = levelRequestTo(c, h, "POST", "/?level=error", "")
+	c.Check(res.Code, Equals, http.StatusOK)
+	c.Check(obj["level"], Equals, "ERROR")
+	c.Check(l.Level(), Equals, ERROR)
+}
+
+func (a *LevelHandlerSuite) TestBadRequests(c *C) {
+	l := NewLogger(nil, INFO)
+	h := NewLevelHandler(l)
+	res, _ := levelRequestTo(c, h, "PUT", "/", `{"level":"LOUD"}`)
+	c.Check(res.Code, Equals, http.StatusBadRequest)
+	res, _ = levelRequestTo(c, h, "PUT", "/", `{}`)
+	c.Check(res.Code, Equals, http.StatusBadRequest)
+	res, _ = levelRequestTo(c, h, "PUT", "/", `{"level":"DEBUG","ttl":"soon"}`)
+	c.Check(res.Code, Equals, http.StatusBadRequest)
+	res, _ = levelRequestTo(c, h, "PUT", "/?level=DEBUG&ttl=-1s", "")
+	c.Check(res.Code, Equals, http.StatusBadRequest)
+	res, _ = levelRequestTo(c, h, "DELETE", "/", "")
+	c.Check(res.Code, Equals, http.StatusMethodNotAllowed)
+	c.Check(res.Header().Get("Allow"), Equals, "GET, HEAD, PUT, POST")
+	c.Check(l.Level(), Equals, INFO)
+}
+
+func (a *LevelHandlerSuite) TestTTL(c *C) {
+	l := NewLogger(nil, INFO)
+	h := NewLevelHandler(l)
+	res, obj := levelRequestTo(c, h, "PUT", "/", `{"level":"DEBUG","ttl":"1h"}`)
+	c.Check(res.Code, Equals, http.StatusOK)
+	c.Check(obj["level"], Equals, "DEBUG")
+	c.Check(obj["restore"], Equals, "INFO")
+	c.Check(obj["expires"], NotNil)
+	// a second temporary change still restores the original level
+	levelRequestTo(c, h, "PUT", "/", `{"level":"TRACE","ttl":"20ms"}`)
+	c.Check(l.Level(), Equals, TRACE)
+	deadline := time.Now().Add(2 * time.Second)
+	for l.Level() != INFO && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.Check(l.Level(), Equals, INFO)
+	_, obj = levelRequestTo(c, h, "GET", "/", "")
+	c.Check(obj, DeepEquals, map[string]interface{}{"level": "INFO"})
+	// a permanent change cancels a pending restore
+	h.Set(DEBUG, 20 * time.Millisecond)
+	h.Set(WARNING, 0)
+	time.Sleep(50 * time.Millisecond)
+	c.Check(l.Level(), Equals, WARNING)
+}
+
+func (a *LevelHandlerSuite) TestDefaultLogger(c *C) {
+	orig := defaultLogger
+	defer func() { defaultLogger = orig }()
+	defaultLogger = NewLogger(nil, ERROR)
+	h := NewLevelHandler(nil)
+	_, obj := levelRequestTo(c, h, "GET", "/", "")
+	c.Check(obj["level"], Equals, "ERROR")
+	levelRequestTo(c, h, "PUT", "/", `{"level":"WARNING"}`)
+	c.Check(defaultLogger.Level(), Equals, WARNING)
+}
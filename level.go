@@ -2,25 +2,34 @@ package logging
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
 
 type LogLevel int
 
+// The built-in levels are spaced apart so that custom levels can be
+// registered between them.  A message is printed when its level is less
+// than or equal to the logger's level.
 const (
 	NONE     LogLevel = 0
-	LOG      LogLevel = 1
-	CRITICAL LogLevel = 2
-	ERROR    LogLevel = 3
-	WARNING  LogLevel = 4
-	INFO     LogLevel = 5
-	TRACE    LogLevel = 6
-	DEBUG    LogLevel = 7
+	LOG      LogLevel = 10
+	CRITICAL LogLevel = 20
+	ERROR    LogLevel = 30
+	WARNING  LogLevel = 40
+	INFO     LogLevel = 50
+	TRACE    LogLevel = 60
+	DEBUG    LogLevel = 70
 	IGNORED  LogLevel = 100
 )
 
+var llLock sync.RWMutex
+
 var llNames = map[LogLevel]string{
 	NONE:     "",
 	LOG:      "LOG",
@@ -33,18 +42,122 @@ var llNames = map[LogLevel]string{
 	IGNORED:  "IGNORED",
 }
 
+var llColors = map[LogLevel]*Colorizer{
+	DEBUG:    mustColorizer(ColorLightGray, ColorDefault, FontDefault),
+	INFO:     mustColorizer(ColorBlue,      ColorDefault, FontDefault),
+	WARNING:  mustColorizer(ColorYellow,    ColorDefault, FontDefault),
+	ERROR:    mustColorizer(ColorRed,       ColorDefault, FontDefault),
+	CRITICAL: mustColorizer(ColorRed,       ColorDefault, FontBold | FontBlink),
+}
+
+var llWidth = 8
+
+func mustColorizer(fg, bg ColorCode, font FontCode) *Colorizer {
+	c, err := NewColorizer(fg, bg, font)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// RegisterLevel adds a custom level, eg.
+//
+//	const NOTICE = logging.LogLevel(45)
+//	logging.RegisterLevel(NOTICE, "NOTICE", nil)
+//
+// The name is used for printing and parsing, and color (which may be nil)
+// becomes the level's color in loggers created afterwards.  It panics if
+// the value or name is already in use, so it is best called from init.
+func RegisterLevel(value LogLevel, name string, color *Colorizer) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" {
+		panic("logging: empty level name")
+	}
+	llLock.Lock()
+	defer llLock.Unlock()
+	if prev, ok := llNames[value]; ok {
+		panic(fmt.Sprintf("logging: level %d already registered as %s", int(value), prev))
+	}
+	for _, v := range llNames {
+		if v == name {
+			panic(fmt.Sprintf("logging: level %s already registered", name))
+		}
+	}
+	if _, ok := llAliases[name]; ok {
+		panic(fmt.Sprintf("logging: level %s already registered", name))
+	}
+	llNames[value] = name
+	if color != nil {
+		llColors[value] = color
+	}
+	if len(name) > llWidth {
+		llWidth = len(name)
+	}
+}
+
+// defaultLevelColors returns copies of the registered level colors
+func defaultLevelColors() map[LogLevel]*Colorizer {
+	llLock.RLock()
+	defer llLock.RUnlock()
+	m := make(map[LogLevel]*Colorizer, len(llColors))
+	for k, v := range llColors {
+		c := *v
+		m[k] = &c
+	}
+	return m
+}
+
+// levelWidth is the length of the longest level name, but at least 8
+func levelWidth() int {
+	llLock.RLock()
+	defer llLock.RUnlock()
+	return llWidth
+}
+
 func (ll LogLevel) String() string {
+	llLock.RLock()
+	defer llLock.RUnlock()
 	return llNames[ll]
 }
 
 func (ll LogLevel) PaddedString(n int) string {
 	if n <= 0 {
-		n = 8
+		n = levelWidth()
 	}
 	f := fmt.Sprintf("%%%ds", -1 * n)
 	return fmt.Sprintf(f, ll.String())[:n]
 }
 
+var llAliases = map[string]LogLevel{
+	"WARN":  WARNING,
+	"ERR":   ERROR,
+	"CRIT":  CRITICAL,
+	"FATAL": CRITICAL,
+}
+
+// ParseLevel parses a level name case-insensitively.  It also accepts the
+// aliases WARN, ERR, CRIT and FATAL, and the numeric value of any known
+// level.
+func ParseLevel(s string) (LogLevel, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	llLock.RLock()
+	defer llLock.RUnlock()
+	for k, v := range llNames {
+		if v == name {
+			return k, nil
+		}
+	}
+	if ll, ok := llAliases[name]; ok {
+		return ll, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if _, ok := llNames[LogLevel(n)]; ok {
+			return LogLevel(n), nil
+		}
+	}
+	return NONE, errors.Errorf("unknown log level %s", s)
+}
+
 func (ll LogLevel) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ll.String())
 }
@@ -55,10 +168,28 @@ func (ll *LogLevel) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return errors.Wrapf(err, "can't unmarshal log level %s", string(data))
 	}
-	return ll.UnmarshalText(s)
+	return ll.UnmarshalText([]byte(s))
+}
+
+func (ll LogLevel) MarshalText() ([]byte, error) {
+	return []byte(ll.String()), nil
+}
+
+func (ll *LogLevel) UnmarshalText(data []byte) error {
+	level, err := ParseLevel(string(data))
+	if err != nil {
+		return err
+	}
+	*ll = level
+	return nil
 }
 
-func (ll *LogLevel) UnmarshalText(data string) error {
+// UnmarshalString parses an exact, upper case level name.
+//
+// Deprecated: use UnmarshalText or ParseLevel.
+func (ll *LogLevel) UnmarshalString(data string) error {
+	llLock.RLock()
+	defer llLock.RUnlock()
 	for k, v := range llNames {
 		if v == data {
 			*ll = k
@@ -68,3 +199,45 @@ func (ll *LogLevel) UnmarshalText(data string) error {
 	return errors.Errorf("unknown log level %s", data)
 }
 
+// Set implements flag.Value
+func (ll *LogLevel) Set(s string) error {
+	return ll.UnmarshalText([]byte(s))
+}
+
+const LevelFlagName = "loglevel"
+
+type levelFlag struct {
+	l *Logger
+}
+
+func (f levelFlag) String() string {
+	if f.l == nil {
+		return ""
+	}
+	return f.l.Level().String()
+}
+
+func (f levelFlag) Set(s string) error {
+	level, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	f.l.SetLevel(level)
+	return nil
+}
+
+// RegisterLevelFlag adds a -loglevel flag to fs (flag.CommandLine if nil)
+// that sets the level of l (the default logger if nil).
+func RegisterLevelFlag(fs *flag.FlagSet, l *Logger) {
+	RegisterLevelFlagName(fs, LevelFlagName, l)
+}
+
+func RegisterLevelFlagName(fs *flag.FlagSet, name string, l *Logger) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	if l == nil {
+		l = defaultLogger
+	}
+	fs.Var(levelFlag{l: l}, name, "log level (DEBUG, TRACE, INFO, WARNING, ERROR, CRITICAL or LOG)")
+}
@@ -1,7 +1,11 @@
 package logging
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/json"
+	"flag"
+	"io/ioutil"
 
 	. "gopkg.in/check.v1"
 )
@@ -54,12 +58,110 @@ func (a *LevelSuite) TestText(c *C) {
 	for k, v := range exp {
 		ll := NONE
 		llp := &ll
-		err := llp.UnmarshalText(k)
+		err := llp.UnmarshalText([]byte(k))
 		c.Check(err, IsNil)
 		c.Check(ll, Equals, v)
+		data, err := v.MarshalText()
+		c.Check(err, IsNil)
+		c.Check(string(data), Equals, k)
 	}
 	ll := NONE
 	llp := &ll
-	err := llp.UnmarshalText("NONE")
+	err := llp.UnmarshalText([]byte("NONE"))
 	c.Check(err, ErrorMatches, "unknown log level NONE")
+	var _ encoding.TextMarshaler = INFO
+	var _ encoding.TextUnmarshaler = llp
+}
+
+func (a *LevelSuite) TestUnmarshalString(c *C) {
+	ll := NONE
+	c.Check(ll.UnmarshalString("ERROR"), IsNil)
+	c.Check(ll, Equals, ERROR)
+	c.Check(ll.UnmarshalString("error"), ErrorMatches, "unknown log level error")
+	c.Check(ll.UnmarshalString("NONE"), ErrorMatches, "unknown log level NONE")
+}
+
+func (a *LevelSuite) TestParseLevel(c *C) {
+	exp := map[string]LogLevel{
+		"debug": DEBUG,
+		" Info ": INFO,
+		"trace": TRACE,
+		"warn": WARNING,
+		"Warning": WARNING,
+		"err": ERROR,
+		"crit": CRITICAL,
+		"FATAL": CRITICAL,
+		"log": LOG,
+		"30": ERROR,
+		"70": DEBUG,
+		"100": IGNORED,
+	}
+	for k, v := range exp {
+		ll, err := ParseLevel(k)
+		c.Check(err, IsNil, Commentf("%q", k))
+		c.Check(ll, Equals, v, Commentf("%q", k))
+	}
+	_, err := ParseLevel("loud")
+	c.Check(err, ErrorMatches, "unknown log level loud")
+	_, err = ParseLevel("42")
+	c.Check(err, ErrorMatches, "unknown log level 42")
+}
+
+func (a *LevelSuite) TestFlag(c *C) {
+	var ll LogLevel
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&ll, "level", "level")
+	l := NewLogger(nil, WARNING)
+	RegisterLevelFlag(fs, l)
+	c.Check(fs.Parse([]string{"-level", "warn", "-loglevel=debug"}), IsNil)
+	c.Check(ll, Equals, WARNING)
+	c.Check(l.Level(), Equals, DEBUG)
+	c.Check(fs.Lookup(LevelFlagName).Value.String(), Equals, "DEBUG")
+	c.Check(fs.Parse([]string{"-loglevel", "loud"}), ErrorMatches, `invalid value "loud" for flag -loglevel: unknown log level loud`)
+	c.Check(l.Level(), Equals, DEBUG)
+}
+
+func (a *LevelSuite) TestRegisterLevel(c *C) {
+	notice := LogLevel(45)
+	audit := LogLevel(-10)
+	RegisterLevel(notice, "notice", mustColorizer(ColorGreen, ColorDefault, FontDefault))
+	RegisterLevel(audit, "SECURITY-AUDIT", nil)
+	defer func() {
+		llLock.Lock()
+		delete(llNames, notice)
+		delete(llNames, audit)
+		delete(llColors, notice)
+		llWidth = 8
+		llLock.Unlock()
+	}()
+	c.Check(notice.String(), Equals, "NOTICE")
+	c.Check(notice.PaddedString(0), Equals, "NOTICE        ")
+	ll, err := ParseLevel("Notice")
+	c.Check(err, IsNil)
+	c.Check(ll, Equals, notice)
+	ll, err = ParseLevel("45")
+	c.Check(err, IsNil)
+	c.Check(ll, Equals, notice)
+	data, err := json.Marshal(audit)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, `"SECURITY-AUDIT"`)
+	c.Check(json.Unmarshal([]byte(`"security-audit"`), &ll), IsNil)
+	c.Check(ll, Equals, audit)
+	c.Check(func() { RegisterLevel(LogLevel(45), "OTHER", nil) }, PanicMatches, `logging: level 45 already registered as NOTICE`)
+	c.Check(func() { RegisterLevel(LogLevel(46), "Warning", nil) }, PanicMatches, `logging: level WARNING already registered`)
+	c.Check(func() { RegisterLevel(LogLevel(47), "warn", nil) }, PanicMatches, `logging: level WARN already registered`)
+	c.Check(func() { RegisterLevel(LogLevel(48), " ", nil) }, PanicMatches, `logging: empty level name`)
+
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, WARNING)
+	l.SetFlags(0)
+	l.Colorize()
+	c.Check(l.LevelColor(notice).GetForeground(), Equals, ColorGreen)
+	l.Log(notice, "quiet")
+	l.Logln(audit, "always")
+	l.SetLevel(INFO)
+	l.Logf(notice, "%s", "noticed")
+	l.Flush()
+	c.Check(buf.String(), Equals, "SECURITY-AUDIT always\n\033[32;49mNOTICE        \033[0m \033[32;49mnoticed\033[0m\n")
 }
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+type LogfmtFormatter struct {
+	lock sync.RWMutex
+	keyColor *Colorizer
+}
+
+func NewLogfmtFormatter() *LogfmtFormatter {
+	return &LogfmtFormatter{}
+}
+
+func (lf *LogfmtFormatter) SetKeyColor(fg, bg ColorCode, font FontCode) {
+	c, err := NewColorizer(fg, bg, font)
+	if err == nil {
+		lf.lock.Lock()
+		lf.keyColor = c
+		lf.lock.Unlock()
+	}
+}
+
+func (lf *LogfmtFormatter) KeyColor() *Colorizer {
+	lf.lock.RLock()
+	defer lf.lock.RUnlock()
+	return lf.keyColor
+}
+
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// logfmtKey replaces the characters that can't appear in a logfmt key
+func logfmtKey(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (lf *LogfmtFormatter) Format(rec *Record) ([]byte, error) {
+	l := rec.settings()
+	kc := rec.colorizer(l.levelColor[rec.Level], lf.KeyColor())
+	parts := []string{}
+	add := func(key, value string) {
+		key = logfmtKey(key)
+		if kc != nil {
+			key = kc.Colorize(key)
+		}
+		parts = append(parts, key + "=" + logfmtValue(value))
+	}
+	add("ts", rec.Time.Format(time.RFC3339Nano))
+	add("level", rec.Level.String())
+	if rec.Prefix != "" {
+		add("prefix", rec.Prefix)
+	}
+	if l.sourceFormat != nil && rec.Source != nil {
+		add("caller", strings.TrimRight(l.sourceFormat.FormatRecord(rec.Source), ": "))
+	}
+	add("msg", strings.TrimSuffix(rec.Message, "\n"))
+	if rec.Trace != nil {
+		for _, f := range rec.Trace.ids() {
+			add(f.Key, f.ValueString())
+		}
+		if rec.Trace.Duration != 0 {
+			add("duration", rec.Trace.Duration.String())
+		}
+	}
+	for _, f := range rec.Fields {
+		add(f.Key, f.ValueString())
+	}
+	return []byte(strings.Join(parts, " ") + "\n"), nil
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+type LogfmtFormatterSuite struct {}
+var _ = Suite(&LogfmtFormatterSuite{})
+
+func (a *LogfmtFormatterSuite) TestValue(c *C) {
+	c.Check(logfmtValue("plain"), Equals, `plain`)
+	c.Check(logfmtValue(""), Equals, `""`)
+	c.Check(logfmtValue("two words"), Equals, `"two words"`)
+	c.Check(logfmtValue(`say "hi"`), Equals, `"say \"hi\""`)
+	c.Check(logfmtValue("a=b"), Equals, `"a=b"`)
+	c.Check(logfmtValue("line\nbreak"), Equals, `"line\nbreak"`)
+}
+
+func (a *LogfmtFormatterSuite) TestKey(c *C) {
+	c.Check(logfmtKey("user_id"), Equals, "user_id")
+	c.Check(logfmtKey("user id"), Equals, "user_id")
+	c.Check(logfmtKey(`a=b"c`), Equals, "a_b_c")
+	c.Check(logfmtKey("tab\there"), Equals, "tab_here")
+	c.Check(logfmtKey(""), Equals, "_")
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFormatter(NewLogfmtFormatter())
+	l.SetSourceFormat("")
+	l.WithField("user id", "a b").RawLogSync(nil, INFO, "hi")
+	c.Check(buf.String(), Matches, `^ts=\S+ level=INFO msg=hi user_id="a b"\n$`)
+}
+
+func (a *LogfmtFormatterSuite) TestFormat(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFormatter(NewLogfmtFormatter())
+	l = l.WithPrefix("unittest").WithField("user", "bob smith")
+	l.RawLogSync(nil, ERROR, "it broke\nbadly")
+	c.Check(buf.String(), Matches, `^ts=[0-9T:.+-Z]+ level=ERROR prefix=unittest caller=logfmt-formatter_test\.go:[0-9]+ msg="it broke\\nbadly" user="bob smith"\n$`)
+}
+
+func (a *LogfmtFormatterSuite) TestTrace(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFormatter(NewLogfmtFormatter())
+	l.SetSourceFormat("")
+	l.RawWrite(nil, TRACE, "traced", "AAAA BBBB 01.500000s")
+	c.Check(buf.String(), Matches, `^ts=\S+ level=TRACE msg=traced trace_parent=AAAA trace_id=BBBB duration=1.5s\n$`)
+}
+
+func (a *LogfmtFormatterSuite) TestColor(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	lf := NewLogfmtFormatter()
+	lf.SetKeyColor(ColorGreen, ColorDefault, FontDefault)
+	c.Check(lf.KeyColor().GetForeground(), Equals, ColorGreen)
+	l.SetFormatter(lf)
+	l.SetSourceFormat("")
+	l.RawLogSync(nil, INFO, "plain")
+	c.Check(buf.String(), Matches, `^ts=\S+ level=INFO msg=plain\n$`)
+	buf.Reset()
+	l = l.WithColor()
+	l.RawLogSync(nil, INFO, "colored")
+	c.Check(buf.String(), Matches, `^.\[32;49mts.\[0m=\S+ .\[32;49mlevel.\[0m=INFO .\[32;49mmsg.\[0m=colored\n$`)
+}
@@ -6,7 +6,10 @@ import (
 	"io"
 	"log"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	//"github.com/pkg/errors"
@@ -15,10 +18,26 @@ import (
 // required for testing fatal
 var exiter = func(n int) { os.Exit(n) }
 
-type Logger struct {
+type lockedWriter struct {
+	lock sync.Mutex
 	w io.Writer
+}
+
+func (lw *lockedWriter) Write(data []byte) (int, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if lw.w == nil {
+		return len(data), nil
+	}
+	return lw.w.Write(data)
+}
+
+// loggerConfig is an immutable snapshot of a Logger's settings.  Setters
+// copy it, modify the copy and swap it in, so readers never need a lock.
+type loggerConfig struct {
+	w io.Writer
+	out *lockedWriter
 	colorize bool
-	level LogLevel
 	levelColor map[LogLevel]*Colorizer
 	timeFormat string
 	timeZone *time.Location
@@ -28,14 +47,41 @@ type Logger struct {
 	prefix string
 	prefixColor *Colorizer
 	messageColor *Colorizer
+	fields Fields
+	fieldColor *Colorizer
+	formatter Formatter
+	async *asyncQueue
+	sinks []Sink
+	modules *moduleLevels
+	traceBegin bool
+}
+
+func (cfg *loggerConfig) clone() *loggerConfig {
+	c := *cfg
+	c.levelColor = make(map[LogLevel]*Colorizer, len(cfg.levelColor))
+	for k, v := range cfg.levelColor {
+		c.levelColor[k] = v
+	}
+	return &c
+}
+
+type Logger struct {
+	level int32
+	lock sync.Mutex
+	config atomic.Value
+	// queue is the async queue this logger created, which clones share
+	// but only this logger closes
+	queue *asyncQueue
 }
 
 func NewLogger(w io.Writer, level LogLevel) *Logger {
-	l := &Logger{
+	l := &Logger{level: int32(level)}
+	l.queue = newAsyncQueue(DefaultQueueSize, OverflowBlock)
+	l.config.Store(&loggerConfig{
 		w: w,
+		out: &lockedWriter{w: w},
 		colorize: false,
-		level: level,
-		levelColor: map[LogLevel]*Colorizer{},
+		levelColor: defaultLevelColors(),
 		timeFormat: "2006/01/02 15:04:05",
 		timeZone: time.Local,
 		timeColor: nil,
@@ -44,19 +90,33 @@ func NewLogger(w io.Writer, level LogLevel) *Logger {
 		prefix: "",
 		prefixColor: nil,
 		messageColor: nil,
-	}
-	l.SetLevelColor(DEBUG,    ColorLightGray, ColorDefault, FontDefault)
-	l.SetLevelColor(INFO,     ColorBlue,      ColorDefault, FontDefault)
-	l.SetLevelColor(WARNING,  ColorYellow,    ColorDefault, FontDefault)
-	l.SetLevelColor(ERROR,    ColorRed,       ColorDefault, FontDefault)
-	l.SetLevelColor(CRITICAL, ColorRed,       ColorDefault, FontBold | FontBlink)
+		fields: nil,
+		fieldColor: nil,
+		formatter: NewTextFormatter(),
+		async: l.queue,
+		sinks: nil,
+		modules: nil,
+	})
 	l.SetSourceFormat("%{filename}:%{linenumber}:")
 	return l
 }
 
+func (l *Logger) cfg() *loggerConfig {
+	return l.config.Load().(*loggerConfig)
+}
+
+func (l *Logger) update(fn func(cfg *loggerConfig)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	cfg := l.cfg().clone()
+	fn(cfg)
+	l.config.Store(cfg)
+}
+
 func (l *Logger) Clone() *Logger {
-	c := *l
-	return &c
+	c := &Logger{level: atomic.LoadInt32(&l.level)}
+	c.config.Store(l.cfg())
+	return c
 }
 
 func (l *Logger) WithOutput(w io.Writer) *Logger {
@@ -66,27 +126,57 @@ func (l *Logger) WithOutput(w io.Writer) *Logger {
 }
 
 func (l *Logger) SetOutput(w io.Writer) {
-	l.w = w
+	l.update(func(cfg *loggerConfig) {
+		cfg.w = w
+		cfg.out = &lockedWriter{w: w}
+	})
 }
 
 func (l *Logger) Writer() io.Writer {
-	return l.w
+	return l.cfg().w
 }
 
 func (l *Logger) WithColor() *Logger {
-	l.Clone()
-	l.colorize = true
+	l = l.Clone()
+	l.Colorize()
 	return l
 }
 
 func (l *Logger) WithoutColor() *Logger {
-	l.Clone()
-	l.colorize = false
+	l = l.Clone()
+	l.update(func(cfg *loggerConfig) { cfg.colorize = false })
 	return l
 }
 
 func (l *Logger) Colorize() {
-	l.colorize = true
+	l.update(func(cfg *loggerConfig) { cfg.colorize = true })
+}
+
+func (l *Logger) WithFormatter(f Formatter) *Logger {
+	l = l.Clone()
+	l.SetFormatter(f)
+	return l
+}
+
+func (l *Logger) SetFormatter(f Formatter) {
+	if f == nil {
+		f = NewTextFormatter()
+	}
+	l.update(func(cfg *loggerConfig) { cfg.formatter = f })
+}
+
+func (l *Logger) Formatter() Formatter {
+	return l.cfg().formatter
+}
+
+func (l *Logger) WithLayout(layout string) *Logger {
+	l = l.Clone()
+	l.SetLayout(layout)
+	return l
+}
+
+func (l *Logger) SetLayout(layout string) {
+	l.SetFormatter(NewLayoutFormatter(layout))
 }
 
 func (l *Logger) WithLevel(level LogLevel) *Logger {
@@ -96,20 +186,116 @@ func (l *Logger) WithLevel(level LogLevel) *Logger {
 }
 
 func (l *Logger) SetLevel(level LogLevel) {
-	l.level = level
+	atomic.StoreInt32(&l.level, int32(level))
 }
 
 func (l *Logger) Level() LogLevel {
-	return l.level
+	return LogLevel(atomic.LoadInt32(&l.level))
 }
 
-func (l *Logger) WithLevelColor(level LogLevel, fg, bg ColorCode, font FontCode) *Logger {
-	lc := map[LogLevel]*Colorizer{}
-	for k, v := range l.levelColor {
-		lc[k] = v
+// threshold is the most verbose level that the primary output, any sink
+// or any module override would print
+func (l *Logger) threshold() LogLevel {
+	cfg := l.cfg()
+	level := l.Level()
+	if sl, ok := sinkThreshold(cfg); ok && sl > level {
+		level = sl
 	}
+	if cfg.modules != nil && cfg.modules.max > level {
+		level = cfg.modules.max
+	}
+	return level
+}
+
+func sinkThreshold(cfg *loggerConfig) (LogLevel, bool) {
+	if len(cfg.sinks) == 0 {
+		return NONE, false
+	}
+	level := cfg.sinks[0].Level()
+	for _, s := range cfg.sinks[1:] {
+		if sl := s.Level(); sl > level {
+			level = sl
+		}
+	}
+	return level, true
+}
+
+// Enabled reports whether a message at level might be printed by the
+// primary output, a sink, or a module override.
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level <= l.threshold()
+}
+
+// allows reports whether a message at level from the function skip frames
+// above the caller would be printed anywhere.  It runs before the message
+// is formatted or the source record is built.
+func (l *Logger) allows(level LogLevel, skip int) bool {
+	if level > l.threshold() {
+		return false
+	}
+	cfg := l.cfg()
+	if cfg.modules == nil {
+		return true
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(skip + 2, pcs[:]) == 0 {
+		return true
+	}
+	primary := l.Level()
+	if ol, ok := cfg.modules.forPC(pcs[0]); ok {
+		primary = ol
+	}
+	if level <= primary {
+		return true
+	}
+	sl, ok := sinkThreshold(cfg)
+	return ok && level <= sl
+}
+
+func (l *Logger) recordAllowed(rec *Record) bool {
+	primary := l.Level()
+	if rec.levelOverride {
+		primary = rec.overrideLevel
+	}
+	if rec.Level <= primary {
+		return true
+	}
+	sl, ok := sinkThreshold(rec.settings())
+	return ok && rec.Level <= sl
+}
+
+func (l *Logger) WithSink(s Sink) *Logger {
+	l = l.Clone()
+	l.AddSink(s)
+	return l
+}
+
+func (l *Logger) AddSink(s Sink) {
+	l.update(func(cfg *loggerConfig) {
+		sinks := make([]Sink, len(cfg.sinks), len(cfg.sinks) + 1)
+		copy(sinks, cfg.sinks)
+		cfg.sinks = append(sinks, s)
+	})
+}
+
+func (l *Logger) RemoveSink(s Sink) {
+	l.update(func(cfg *loggerConfig) {
+		sinks := make([]Sink, 0, len(cfg.sinks))
+		for _, x := range cfg.sinks {
+			if x != s {
+				sinks = append(sinks, x)
+			}
+		}
+		cfg.sinks = sinks
+	})
+}
+
+func (l *Logger) Sinks() []Sink {
+	return append([]Sink{}, l.cfg().sinks...)
+}
+
+func (l *Logger) WithLevelColor(level LogLevel, fg, bg ColorCode, font FontCode) *Logger {
 	l = l.Clone()
-	l.levelColor = lc
 	l.SetLevelColor(level, fg, bg, font)
 	return l
 }
@@ -117,12 +303,12 @@ func (l *Logger) WithLevelColor(level LogLevel, fg, bg ColorCode, font FontCode)
 func (l *Logger) SetLevelColor(level LogLevel, fg, bg ColorCode, font FontCode) {
 	c, err := NewColorizer(fg, bg, font)
 	if err == nil {
-		l.levelColor[level] = c
+		l.update(func(cfg *loggerConfig) { cfg.levelColor[level] = c })
 	}
 }
 
 func (l *Logger) LevelColor(level LogLevel) *Colorizer {
-	return l.levelColor[level]
+	return l.cfg().levelColor[level]
 }
 
 func (l *Logger) WithTimeFormat(timeFormat string) *Logger {
@@ -132,11 +318,11 @@ func (l *Logger) WithTimeFormat(timeFormat string) *Logger {
 }
 
 func (l *Logger) SetTimeFormat(timeFormat string) {
-	l.timeFormat = timeFormat
+	l.update(func(cfg *loggerConfig) { cfg.timeFormat = timeFormat })
 }
 
 func (l *Logger) TimeFormat() string {
-	return l.timeFormat
+	return l.cfg().timeFormat
 }
 
 func (l *Logger) WithTimeZone(timeZone *time.Location) *Logger {
@@ -146,11 +332,11 @@ func (l *Logger) WithTimeZone(timeZone *time.Location) *Logger {
 }
 
 func (l *Logger) SetTimeZone(timeZone *time.Location) {
-	l.timeZone = timeZone
+	l.update(func(cfg *loggerConfig) { cfg.timeZone = timeZone })
 }
 
 func (l *Logger) TimeZone() *time.Location {
-	return l.timeZone
+	return l.cfg().timeZone
 }
 
 func (l *Logger) WithTimeColor(fg, bg ColorCode, font FontCode) *Logger {
@@ -162,12 +348,12 @@ func (l *Logger) WithTimeColor(fg, bg ColorCode, font FontCode) *Logger {
 func (l *Logger) SetTimeColor(fg, bg ColorCode, font FontCode) {
 	c, err := NewColorizer(fg, bg, font)
 	if err == nil {
-		l.timeColor = c
+		l.update(func(cfg *loggerConfig) { cfg.timeColor = c })
 	}
 }
 
 func (l *Logger) TimeColor() *Colorizer {
-	return l.timeColor
+	return l.cfg().timeColor
 }
 
 func (l *Logger) WithSourceFormat(format string) *Logger {
@@ -177,15 +363,15 @@ func (l *Logger) WithSourceFormat(format string) *Logger {
 }
 
 func (l *Logger) SetSourceFormat(format string) {
-	if format == "" {
-		l.sourceFormat = nil
-	} else {
-		l.sourceFormat = NewSourceFormatter(format)
+	var sf *SourceFormatter
+	if format != "" {
+		sf = NewSourceFormatter(format)
 	}
+	l.update(func(cfg *loggerConfig) { cfg.sourceFormat = sf })
 }
 
 func (l *Logger) SourceFormat() *SourceFormatter {
-	return l.sourceFormat
+	return l.cfg().sourceFormat
 }
 
 func (l *Logger) WithSourceColor(fg, bg ColorCode, font FontCode) *Logger {
@@ -197,12 +383,12 @@ func (l *Logger) WithSourceColor(fg, bg ColorCode, font FontCode) *Logger {
 func (l *Logger) SetSourceColor(fg, bg ColorCode, font FontCode) {
 	c, err := NewColorizer(fg, bg, font)
 	if err == nil {
-		l.sourceColor = c
+		l.update(func(cfg *loggerConfig) { cfg.sourceColor = c })
 	}
 }
 
 func (l *Logger) SourceColor() *Colorizer {
-	return l.sourceColor
+	return l.cfg().sourceColor
 }
 
 func (l *Logger) WithPrefix(prefix string) *Logger {
@@ -212,11 +398,11 @@ func (l *Logger) WithPrefix(prefix string) *Logger {
 }
 
 func (l *Logger) SetPrefix(prefix string) {
-	l.prefix = prefix
+	l.update(func(cfg *loggerConfig) { cfg.prefix = prefix })
 }
 
 func (l *Logger) Prefix() string {
-	return l.prefix
+	return l.cfg().prefix
 }
 
 func (l *Logger) WithPrefixColor(fg, bg ColorCode, font FontCode) *Logger {
@@ -228,12 +414,12 @@ func (l *Logger) WithPrefixColor(fg, bg ColorCode, font FontCode) *Logger {
 func (l *Logger) SetPrefixColor(fg, bg ColorCode, font FontCode) {
 	c, err := NewColorizer(fg, bg, font)
 	if err == nil {
-		l.prefixColor = c
+		l.update(func(cfg *loggerConfig) { cfg.prefixColor = c })
 	}
 }
 
 func (l *Logger) PrefixColor() *Colorizer {
-	return l.prefixColor
+	return l.cfg().prefixColor
 }
 
 func (l *Logger) WithMessageColor(fg, bg ColorCode, font FontCode) *Logger {
@@ -245,12 +431,49 @@ func (l *Logger) WithMessageColor(fg, bg ColorCode, font FontCode) *Logger {
 func (l *Logger) SetMessageColor(fg, bg ColorCode, font FontCode) {
 	c, err := NewColorizer(fg, bg, font)
 	if err == nil {
-		l.messageColor = c
+		l.update(func(cfg *loggerConfig) { cfg.messageColor = c })
 	}
 }
 
 func (l *Logger) MessageColor() *Colorizer {
-	return l.messageColor
+	return l.cfg().messageColor
+}
+
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	l = l.Clone()
+	l.update(func(cfg *loggerConfig) {
+		cfg.fields = cfg.fields.with(Field{Key: key, Value: value})
+	})
+	return l
+}
+
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	l = l.Clone()
+	l.update(func(cfg *loggerConfig) {
+		cfg.fields = cfg.fields.with(fieldsFromMap(fields)...)
+	})
+	return l
+}
+
+func (l *Logger) Fields() Fields {
+	return l.cfg().fields
+}
+
+func (l *Logger) WithFieldColor(fg, bg ColorCode, font FontCode) *Logger {
+	l = l.Clone()
+	l.SetFieldColor(fg, bg, font)
+	return l
+}
+
+func (l *Logger) SetFieldColor(fg, bg ColorCode, font FontCode) {
+	c, err := NewColorizer(fg, bg, font)
+	if err == nil {
+		l.update(func(cfg *loggerConfig) { cfg.fieldColor = c })
+	}
+}
+
+func (l *Logger) FieldColor() *Colorizer {
+	return l.cfg().fieldColor
 }
 
 func (l *Logger) WithFlags(flags int) *Logger {
@@ -293,102 +516,138 @@ func (l *Logger) SetFlags(flags int) {
 	}
 }
 
-func (l *Logger) getColorizer(defaultColorizer, colorizer *Colorizer) *Colorizer {
-	if !l.colorize {
-		return nil
-	}
-	if colorizer != nil {
-		return colorizer
-	}
-	return defaultColorizer
-}
-
 func (l *Logger) RawWrite(ctx context.Context, level LogLevel, message string, trace ...string) (int, error) {
-	if level > l.level {
+	skip := getDepth(ctx)
+	if !l.allows(level, skip + 1) {
 		return 0, nil
 	}
-	skip := getDepth(ctx)
 	sr := NewSourceRecord(skip + 1)
 	return l.RawWriteWithSource(ctx, level, sr, message, trace...)
 }
 
 func (l *Logger) RawWriteAsync(ctx context.Context, level LogLevel, message string, trace ...string) {
-	if level > l.level {
+	skip := getDepth(ctx)
+	if !l.allows(level, skip + 1) {
 		return
 	}
-	skip := getDepth(ctx)
 	sr := NewSourceRecord(skip + 1)
-	go func() {
-		l.RawWriteWithSource(ctx, level, sr, message, trace...)
-	}()
+	rec := l.NewRecord(ctx, level, sr, message)
+	if !l.recordAllowed(rec) {
+		return
+	}
+	if len(trace) > 0 {
+		rec.Trace = parseTraceInfo(trace[0])
+	}
+	l.writeAsync(rec)
+}
+
+func (l *Logger) writeAsync(rec *Record) {
+	if !rec.settings().async.enqueue(&asyncEntry{l: l, rec: rec}) {
+		l.WriteRecord(rec)
+	}
+}
+
+func (l *Logger) WithAsyncQueue(size int, policy OverflowPolicy) *Logger {
+	l = l.Clone()
+	l.SetAsyncQueue(size, policy)
+	return l
+}
+
+// SetAsyncQueue gives the logger a queue of its own.  The old queue is
+// closed if it belonged to this logger rather than one it was cloned from.
+func (l *Logger) SetAsyncQueue(size int, policy OverflowPolicy) {
+	q := newAsyncQueue(size, policy)
+	var old *asyncQueue
+	l.update(func(cfg *loggerConfig) {
+		cfg.async = q
+		old = l.queue
+		l.queue = q
+	})
+	if old != nil {
+		old.close()
+	}
+}
+
+func (l *Logger) Dropped() uint64 {
+	return l.cfg().async.droppedCount()
+}
+
+func (l *Logger) Flush() {
+	l.cfg().async.flush()
+}
+
+// Close writes out the logger's queued messages and stops its queue, so
+// that later messages are written synchronously.  A clone that shares its
+// parent's queue only waits for the queue to empty.
+func (l *Logger) Close() {
+	l.lock.Lock()
+	own := l.queue
+	l.lock.Unlock()
+	q := l.cfg().async
+	if q != own {
+		q.flush()
+		return
+	}
+	q.close()
 }
 
 func (l *Logger) RawWriteWithSource(ctx context.Context, level LogLevel, sr *SourceRecord, message string, trace ...string) (int, error) {
-	if level > l.level {
+	if level > l.threshold() {
 		return 0, nil
 	}
-	dc := l.getColorizer(l.levelColor[level], nil)
-	line := ""
-	if l.timeFormat != "" {
-		t := time.Now()
-		if l.timeZone != nil {
-			t = t.In(l.timeZone)
-		}
-		c := l.getColorizer(dc, l.timeColor)
-		if c != nil {
-			line += c.Colorize(t.Format(l.timeFormat))
-		} else {
-			line += t.Format(l.timeFormat)
-		}
-		line += " "
+	rec := l.NewRecord(ctx, level, sr, message)
+	if !l.recordAllowed(rec) {
+		return 0, nil
 	}
-	if dc != nil {
-		line += dc.Colorize(level.PaddedString(8))
-	} else {
-		line += level.PaddedString(8)
+	if len(trace) > 0 {
+		rec.Trace = parseTraceInfo(trace[0])
 	}
-	line += " "
-	if l.prefix != "" {
-		c := l.getColorizer(dc, l.prefixColor)
-		if c != nil {
-			line += c.Colorize(l.prefix)
-		} else {
-			line += l.prefix
-		}
-		line += " "
+	// let queued async messages out first so sync writes stay in call order
+	rec.config.async.flush()
+	return l.WriteRecord(rec)
+}
+
+func (l *Logger) WriteRecord(rec *Record) (int, error) {
+	if rec.Logger == nil {
+		rec.Logger = l
 	}
-	if len(trace) > 0 {
-		line += dc.Colorize(trace[0]) + " "
+	if rec.config == nil {
+		rec.config = l.cfg()
 	}
-	if l.sourceFormat != nil {
-		c := l.getColorizer(dc, l.sourceColor)
-		if c != nil {
-			line += c.Colorize(l.sourceFormat.FormatRecord(sr))
-		} else {
-			line += l.sourceFormat.FormatRecord(sr)
+	var n int
+	var err error
+	primary := l.Level()
+	if rec.levelOverride {
+		primary = rec.overrideLevel
+	}
+	if rec.Level <= primary && rec.config.w != nil {
+		var data []byte
+		data, err = rec.config.formatter.Format(rec)
+		if err == nil {
+			n, err = rec.config.out.Write(data)
 		}
-		line += " "
 	}
-	c := l.getColorizer(dc, l.messageColor)
-	if c != nil {
-		line += c.Colorize(strings.TrimSpace(message))
-	} else {
-		line += strings.TrimSpace(message)
+	for _, s := range rec.config.sinks {
+		serr := s.Log(rec)
+		if serr != nil && err == nil {
+			err = serr
+		}
 	}
-	line += "\n"
-	return l.w.Write([]byte(line))
+	return n, err
 }
 
 func (l *Logger) RawStackTrace(ctx context.Context, prefix string) {
 	skip := getDepth(ctx)
+	cfg := l.cfg()
 	padding := ""
-	if l.timeFormat != "" {
+	if cfg.timeFormat != "" {
 		t := time.Now()
-		if l.timeZone != nil {
-			t = t.In(l.timeZone)
+		if cfg.timeZone != nil {
+			t = t.In(cfg.timeZone)
 		}
-		padding += t.Format(l.timeFormat) + " "
+		padding += t.Format(cfg.timeFormat) + " "
 	}
+	cfg.async.flush()
 	first := true
 	for {
 		sr := NewSourceRecord(skip)
@@ -397,54 +656,53 @@ func (l *Logger) RawStackTrace(ctx context.Context, prefix string) {
 		}
 		skip += 1
 		line := fmt.Sprintf("%s%s %s.%s()\n", padding, prefix, sr.Package, sr.QualifiedFunction)
-		l.w.Write([]byte(line))
+		cfg.out.Write([]byte(line))
 		if first {
 			padding = strings.Repeat(" ", len(padding))
 		}
 		line = fmt.Sprintf("%s%s     %s:%d\n", padding, prefix, sr.FullPath, sr.LineNumber)
-		l.w.Write([]byte(line))
+		cfg.out.Write([]byte(line))
 	}
 }
 
 func (l *Logger) RawLogSync(ctx context.Context, level LogLevel, args ...interface{}) {
-	if l.level >= level {
+	if l.allows(level, getDepth(ctx) + 1) {
 		l.RawWrite(deepen(ctx), level, fmt.Sprint(args...))
 	}
 }
 
 func (l *Logger) RawLoglnSync(ctx context.Context, level LogLevel, args ...interface{}) {
-	if l.level >= level {
+	if l.allows(level, getDepth(ctx) + 1) {
 		l.RawWrite(deepen(ctx), level, fmt.Sprintln(args...))
 	}
 }
 
 func (l *Logger) RawLogfSync(ctx context.Context, level LogLevel, format string, args ...interface{}) {
-	if l.level >= level {
+	if l.allows(level, getDepth(ctx) + 1) {
 		l.RawWrite(deepen(ctx), level, fmt.Sprintf(format, args...))
 	}
 }
 
 func (l *Logger) RawLog(ctx context.Context, level LogLevel, args ...interface{}) {
-	if l.level >= level {
+	if l.allows(level, getDepth(ctx) + 1) {
 		l.RawWriteAsync(deepen(ctx), level, fmt.Sprint(args...))
 	}
 }
 
 func (l *Logger) RawLogln(ctx context.Context, level LogLevel, args ...interface{}) {
-	if l.level >= level {
+	if l.allows(level, getDepth(ctx) + 1) {
 		l.RawWriteAsync(deepen(ctx), level, fmt.Sprintln(args...))
 	}
 }
 
 func (l *Logger) RawLogf(ctx context.Context, level LogLevel, format string, args ...interface{}) {
-	if l.level >= level {
+	if l.allows(level, getDepth(ctx) + 1) {
 		l.RawWriteAsync(deepen(ctx), level, fmt.Sprintf(format, args...))
 	}
 }
 
 func (l *Logger) Write(data []byte) (int, error) {
 	return l.RawWrite(withDepth(nil, 3), LOG, string(data))
-	//return l.w.Write(data)
 }
 
 func (l *Logger) Print(args ...interface{}) {
@@ -459,6 +717,18 @@ func (l *Logger) Printf(format string, args ...interface{}) {
 	l.RawLogf(withDepth(nil, 1), NONE, format, args...)
 }
 
+func (l *Logger) Log(level LogLevel, args ...interface{}) {
+	l.RawLog(withDepth(nil, 1), level, args...)
+}
+
+func (l *Logger) Logln(level LogLevel, args ...interface{}) {
+	l.RawLogln(withDepth(nil, 1), level, args...)
+}
+
+func (l *Logger) Logf(level LogLevel, format string, args ...interface{}) {
+	l.RawLogf(withDepth(nil, 1), level, format, args...)
+}
+
 func (l *Logger) Debug(args ...interface{}) {
 	l.RawLog(withDepth(nil, 1), DEBUG, args...)
 }
@@ -509,36 +779,42 @@ func (l *Logger) Errorf(format string, args ...interface{}) {
 
 func (l *Logger) Fatal(args ...interface{}) {
 	l.RawLogSync(withDepth(nil, 1), CRITICAL, args...)
+	l.Flush()
 	exiter(1)
 }
 
 func (l *Logger) Fatalln(args ...interface{}) {
 	l.RawLoglnSync(withDepth(nil, 1), CRITICAL, args...)
+	l.Flush()
 	exiter(1)
 }
 
 func (l *Logger) Fatalf(format string, args ...interface{}) {
 	l.RawLogfSync(withDepth(nil, 1), CRITICAL, format, args...)
+	l.Flush()
 	exiter(1)
 }
 
 func (l *Logger) Panic(args ...interface{}) {
 	l.RawLogSync(withDepth(nil, 1), CRITICAL, args...)
+	l.Flush()
 	panic(fmt.Sprint(args...))
 }
 
 func (l *Logger) Panicln(args ...interface{}) {
 	l.RawLoglnSync(withDepth(nil, 1), CRITICAL, args...)
+	l.Flush()
 	panic(fmt.Sprintln(args...))
 }
 
 func (l *Logger) Panicf(format string, args ...interface{}) {
 	l.RawLogfSync(withDepth(nil, 1), CRITICAL, format, args...)
+	l.Flush()
 	panic(fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) StackTrace() {
-	l.RawStackTrace(withDepth(nil, 1), l.prefix)
+	l.RawStackTrace(withDepth(nil, 1), l.Prefix())
 }
 
 func (l *Logger) MakeDefault() {
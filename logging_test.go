@@ -19,6 +19,7 @@ type LoggingSuite struct {}
 var _ = Suite(&LoggingSuite{})
 
 type Buffer struct {
+	lock sync.Mutex
 	buf *bytes.Buffer
 	wait *sync.Mutex
 	written bool
@@ -34,6 +35,8 @@ func NewBuffer() *Buffer {
 }
 
 func (b *Buffer) Write(data []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	defer func() {
 		written := b.written
 		b.written = true
@@ -45,20 +48,29 @@ func (b *Buffer) Write(data []byte) (int, error) {
 }
 
 func (b *Buffer) Wait() {
-	b.wait.Lock()
+	b.lock.Lock()
+	wait := b.wait
+	b.lock.Unlock()
+	wait.Lock()
+	b.lock.Lock()
 	b.written = false
+	b.lock.Unlock()
 }
 
 func (b *Buffer) Reset() {
 	wait := &sync.Mutex{}
 	wait.Lock()
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	b.buf.Reset()
 	b.wait = wait
 	b.written = false
 }
 
 func (b *Buffer) Bytes() []byte {
-	return b.buf.Bytes()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
 }
 
 func (a *LoggingSuite) TestNewLogger(c *C) {
@@ -379,6 +391,11 @@ func (a *LoggingSuite) TestStackTrace(c *C) {
 	c.Check(lines[3], Matches, `           trace     /.*/github.com/rclancey/logging/logging_test.go:[0-9]+`)
 }
 
+func (a *LoggingSuite) TestStackTraceNoOutput(c *C) {
+	l := NewLogger(nil, INFO)
+	c.Check(l.StackTrace, Not(PanicMatches), ".*")
+}
+
 func (a *LoggingSuite) TestMakeDefault(c *C) {
 	buf := bytes.NewBuffer([]byte{})
 	l := NewLogger(buf, INFO)
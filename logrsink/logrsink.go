@@ -0,0 +1,106 @@
+// Package logrsink adapts a logging.Logger to the go-logr/logr interface,
+// for use with libraries such as controller-runtime.
+package logrsink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/rclancey/logging"
+)
+
+// Sink is a logr.LogSink writing to a logging.Logger.  V-level 0 logs at
+// INFO, 1 at TRACE and anything higher at DEBUG; errors log at ERROR.
+// Names are joined to the logger's prefix with dots, and key/value pairs
+// become fields.
+type Sink struct {
+	l *logging.Logger
+	depth int
+}
+
+// New returns a logr.Logger writing to l, or the default logger if l is
+// nil.
+func New(l *logging.Logger) logr.Logger {
+	return logr.New(NewSink(l))
+}
+
+func NewSink(l *logging.Logger) *Sink {
+	if l == nil {
+		l = logging.FromContext(nil)
+	}
+	return &Sink{l: l}
+}
+
+func (s *Sink) Logger() *logging.Logger {
+	return s.l
+}
+
+func (s *Sink) Init(info logr.RuntimeInfo) {
+	s.depth += info.CallDepth
+}
+
+func vLevel(level int) logging.LogLevel {
+	switch {
+	case level <= 0:
+		return logging.INFO
+	case level == 1:
+		return logging.TRACE
+	}
+	return logging.DEBUG
+}
+
+func (s *Sink) Enabled(level int) bool {
+	return s.l.Enabled(vLevel(level))
+}
+
+// the caller of logr.Logger's method is one frame above it, and it is
+// s.depth frames above us
+func (s *Sink) ctx() context.Context {
+	return logging.WithDepth(nil, s.depth + 1)
+}
+
+func (s *Sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	withValues(s.l, keysAndValues).RawLog(s.ctx(), vLevel(level), msg)
+}
+
+func (s *Sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	l := s.l.WithField("error", err)
+	withValues(l, keysAndValues).RawLog(s.ctx(), logging.ERROR, msg)
+}
+
+func (s *Sink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &Sink{l: withValues(s.l, keysAndValues), depth: s.depth}
+}
+
+func (s *Sink) WithName(name string) logr.LogSink {
+	prefix := s.l.Prefix()
+	if prefix != "" {
+		prefix += "." + name
+	} else {
+		prefix = name
+	}
+	return &Sink{l: s.l.WithPrefix(prefix), depth: s.depth}
+}
+
+func (s *Sink) WithCallDepth(depth int) logr.LogSink {
+	return &Sink{l: s.l, depth: s.depth + depth}
+}
+
+func withValues(l *logging.Logger, keysAndValues []interface{}) *logging.Logger {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value interface{} = "<no-value>"
+		if i + 1 < len(keysAndValues) {
+			value = keysAndValues[i + 1]
+		}
+		if m, ok := value.(logr.Marshaler); ok {
+			value = m.MarshalLog()
+		}
+		l = l.WithField(key, value)
+	}
+	return l
+}
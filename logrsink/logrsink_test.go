@@ -0,0 +1,58 @@
+package logrsink
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/rclancey/logging"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type LogrSuite struct {}
+var _ = Suite(&LogrSuite{})
+
+type secret string
+
+func (s secret) MarshalLog() interface{} {
+	return "***"
+}
+
+func logHelper(log logr.Logger, msg string) {
+	log.WithCallDepth(1).Info(msg)
+}
+
+func (a *LogrSuite) TestSink(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := logging.NewLogger(buf, logging.TRACE)
+	l.SetFlags(0)
+	l.SetSourceFormat("%{function}:")
+	l.SetPrefix("op")
+	log := New(l)
+	c.Check(log.V(1).Enabled(), Equals, true)
+	c.Check(log.V(2).Enabled(), Equals, false)
+	log.Info("starting", "replicas", 3, "token", secret("hunter2"))
+	log.V(1).Info("verbose")
+	log.V(2).Info("hidden")
+	log = log.WithName("ctrl").WithValues("ns", "default")
+	log.WithName("pod").Error(errors.New("boom"), "reconcile failed", "pod", "web-1", "dangling")
+	logHelper(log, "from helper")
+	l.Flush()
+	c.Check(buf.String(), Equals, "" +
+		"INFO     op TestSink: starting replicas=3 token=***\n" +
+		"TRACE    op TestSink: verbose\n" +
+		"ERROR    op.ctrl.pod TestSink: reconcile failed ns=default error=boom pod=web-1 dangling=<no-value>\n" +
+		"INFO     op.ctrl TestSink: from helper ns=default\n")
+}
+
+func (a *LogrSuite) TestLevels(c *C) {
+	c.Check(vLevel(0), Equals, logging.INFO)
+	c.Check(vLevel(1), Equals, logging.TRACE)
+	c.Check(vLevel(2), Equals, logging.DEBUG)
+	c.Check(vLevel(10), Equals, logging.DEBUG)
+	sink := NewSink(nil)
+	c.Check(sink.Logger(), Equals, logging.FromContext(nil))
+}
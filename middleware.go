@@ -0,0 +1,183 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// responseRecorder wraps an http.ResponseWriter to capture the status and
+// size of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes int64
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	n, err := rr.ResponseWriter.Write(data)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		if !rr.wroteHeader {
+			rr.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	rr.wroteHeader = true
+	return h.Hijack()
+}
+
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// HTTPMiddleware logs a line for each request handled by the wrapped
+// handler, at ERROR for 5xx responses, WARNING for 4xx and INFO otherwise.
+// Each request runs in a span that continues the trace in its traceparent
+// header, if any, with a clone of the logger stored in its context.
+// Handler panics are logged with a stack trace and answered with a 500.
+// Requests can also be written to an AccessLog, with times in the logger's
+// time zone.
+type HTTPMiddleware struct {
+	logger *Logger
+	accessLog *AccessLog
+}
+
+// NewHTTPMiddleware creates a middleware logging to l, or the default
+// logger if l is nil.
+func NewHTTPMiddleware(l *Logger) *HTTPMiddleware {
+	return &HTTPMiddleware{logger: l}
+}
+
+func (m *HTTPMiddleware) getLogger() *Logger {
+	if m.logger == nil {
+		return defaultLogger
+	}
+	return m.logger
+}
+
+func (m *HTTPMiddleware) SetAccessLog(al *AccessLog) {
+	m.accessLog = al
+}
+
+func (m *HTTPMiddleware) AccessLog() *AccessLog {
+	return m.accessLog
+}
+
+func statusLevel(status int) LogLevel {
+	switch {
+	case status >= 500:
+		return ERROR
+	case status >= 400:
+		return WARNING
+	}
+	return INFO
+}
+
+func (m *HTTPMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		l := m.getLogger().Clone()
+		ctx := r.Context()
+		if tp := r.Header.Get("traceparent"); tp != "" {
+			if tctx, err := ContextWithTraceparent(ctx, tp); err == nil {
+				ctx = tctx
+			}
+		}
+		ctx = NewContext(withSpan(ctx, newChildSpan(ctx)), l)
+		rr := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				l.RawWrite(ctx, ERROR, fmt.Sprintf("panic serving %s %s: %v", r.Method, r.URL.RequestURI(), p))
+				l.RawStackTrace(withDepth(ctx, 1), l.Prefix())
+				if !rr.wroteHeader {
+					http.Error(rr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				} else {
+					rr.status = http.StatusInternalServerError
+				}
+			}
+			elapsed := time.Since(start)
+			m.logRequest(ctx, l, r, rr, elapsed)
+			if m.accessLog != nil {
+				if tz := l.TimeZone(); tz != nil {
+					start = start.In(tz)
+				}
+				err := m.accessLog.Log(&AccessEntry{
+					Request: r,
+					Time: start,
+					Status: rr.status,
+					Bytes: rr.bytes,
+					Duration: elapsed,
+					ResponseHeader: rr.Header(),
+				})
+				if err != nil {
+					l.RawWrite(ctx, ERROR, fmt.Sprintf("can't write access log: %v", err))
+				}
+			}
+		}()
+		next.ServeHTTP(rr, r.WithContext(ctx))
+	})
+}
+
+func (m *HTTPMiddleware) logRequest(ctx context.Context, l *Logger, r *http.Request, rr *responseRecorder, elapsed time.Duration) {
+	level := statusLevel(rr.status)
+	if !l.Enabled(level) {
+		return
+	}
+	rec := l.NewRecord(ctx, level, NewSourceRecord(1), fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rr.status))
+	if !l.recordAllowed(rec) {
+		return
+	}
+	rec.Fields = rec.Fields.with(
+		Field{Key: "remote", Value: r.RemoteAddr},
+		Field{Key: "method", Value: r.Method},
+		Field{Key: "uri", Value: r.URL.RequestURI()},
+		Field{Key: "proto", Value: r.Proto},
+		Field{Key: "status", Value: rr.status},
+		Field{Key: "bytes", Value: rr.bytes},
+		Field{Key: "latency", Value: elapsed},
+	)
+	l.writeAsync(rec)
+}
+
+// Middleware wraps next with an HTTPMiddleware logging to l.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return NewHTTPMiddleware(l).Handler(next)
+}
+
+// Middleware wraps next with an HTTPMiddleware logging to the default
+// logger.
+func Middleware(next http.Handler) http.Handler {
+	return NewHTTPMiddleware(nil).Handler(next)
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type MiddlewareSuite struct {}
+var _ = Suite(&MiddlewareSuite{})
+
+func jsonLines(c *C, buf *bytes.Buffer) []map[string]interface{} {
+	objs := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		obj := map[string]interface{}{}
+		c.Assert(json.Unmarshal([]byte(line), &obj), IsNil, Commentf("%s", line))
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+func (a *MiddlewareSuite) TestAccessLine(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, INFO)
+	l.SetFormatter(NewJSONFormatter())
+	var reqLogger *Logger
+	var spanId string
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqLogger = FromContext(r.Context())
+		spanId = SpanID(r.Context())
+		Infoln(r.Context(), "handling")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	req := httptest.NewRequest("GET", "/hello?x=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	c.Check(res.Code, Equals, http.StatusOK)
+	c.Check(res.Body.String(), Equals, "hello")
+	c.Check(reqLogger, NotNil)
+	c.Check(reqLogger != l, Equals, true)
+	l.Flush()
+	objs := jsonLines(c, buf)
+	c.Assert(objs, HasLen, 2)
+	c.Check(objs[0]["msg"], Equals, "handling")
+	c.Check(objs[0]["trace_id"], Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Check(objs[0]["trace_parent"], Equals, "00f067aa0ba902b7")
+	c.Check(objs[0]["span_id"], Equals, spanId)
+	c.Check(objs[1]["level"], Equals, "INFO")
+	c.Check(objs[1]["msg"], Equals, "GET /hello 200")
+	c.Check(objs[1]["trace_id"], Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Check(objs[1]["remote"], Equals, "10.0.0.1:1234")
+	c.Check(objs[1]["method"], Equals, "GET")
+	c.Check(objs[1]["uri"], Equals, "/hello?x=1")
+	c.Check(objs[1]["proto"], Equals, "HTTP/1.1")
+	c.Check(objs[1]["status"], Equals, float64(200))
+	c.Check(objs[1]["bytes"], Equals, float64(5))
+	c.Check(objs[1]["latency"], Matches, `[0-9.]+[µnm]?s`)
+
+	buf.Reset()
+	req = httptest.NewRequest("GET", "/missing", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	l.Flush()
+	objs = jsonLines(c, buf)
+	c.Assert(objs, HasLen, 2)
+	c.Check(objs[0]["trace_id"], Matches, `[0-9a-f]{32}`)
+	_, ok := objs[0]["trace_parent"]
+	c.Check(ok, Equals, false)
+	c.Check(objs[1]["level"], Equals, "WARNING")
+	c.Check(objs[1]["msg"], Equals, "GET /missing 404")
+	c.Check(objs[1]["trace_id"], Equals, objs[0]["trace_id"])
+}
+
+func (a *MiddlewareSuite) TestLevels(c *C) {
+	c.Check(statusLevel(200), Equals, INFO)
+	c.Check(statusLevel(302), Equals, INFO)
+	c.Check(statusLevel(404), Equals, WARNING)
+	c.Check(statusLevel(503), Equals, ERROR)
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, WARNING)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	l.Flush()
+	c.Check(buf.String(), Equals, "")
+}
+
+func (a *MiddlewareSuite) TestPanic(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, INFO)
+	l.SetFlags(0)
+	l.SetPrefix("web")
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("kaboom")
+	}))
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("POST", "/boom", nil))
+	l.Flush()
+	c.Check(res.Code, Equals, http.StatusInternalServerError)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(len(lines) > 4, Equals, true)
+	c.Check(lines[0], Matches, `ERROR    web [0-9a-f]{32} - [0-9a-f]{16} panic serving POST /boom: kaboom`)
+	c.Check(buf.String(), Matches, `(?s).*web github.com/rclancey/logging.\(\*MiddlewareSuite\).TestPanic.func1\(\)\n.*`)
+	c.Check(lines[len(lines) - 1], Matches, `ERROR    web [0-9a-f]{32} - [0-9a-f]{16} POST /boom 500 remote=\S+ method=POST uri=/boom proto=HTTP/1.1 status=500 bytes=\d+ latency=\S+`)
+}
+
+func (a *MiddlewareSuite) TestAbortHandler(c *C) {
+	l := NewLogger(nil, INFO)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	c.Check(func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}, PanicMatches, `net/http: abort Handler`)
+}
+
+func (a *MiddlewareSuite) TestDefaultLogger(c *C) {
+	orig := defaultLogger
+	defer func() { defaultLogger = orig }()
+	buf := bytes.NewBuffer([]byte{})
+	defaultLogger = NewLogger(buf, INFO)
+	defaultLogger.SetFlags(0)
+	var ctx context.Context
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/thing", nil))
+	defaultLogger.Flush()
+	c.Check(FromContext(ctx) != defaultLogger, Equals, true)
+	c.Check(buf.String(), Matches, `INFO     [0-9a-f]{32} - [0-9a-f]{16} DELETE /thing 204 .*\n`)
+}
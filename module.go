@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type moduleRule struct {
+	pattern string
+	level LogLevel
+}
+
+type moduleMatch struct {
+	level LogLevel
+	ok bool
+}
+
+// moduleLevels holds glog-style vmodule overrides.  Lookups are cached by
+// program counter, so after the first call from a given line the cost is a
+// map read.
+type moduleLevels struct {
+	spec string
+	rules []moduleRule
+	max LogLevel
+	pcCache sync.Map
+	srcCache sync.Map
+}
+
+func parseModuleLevels(spec string) (*moduleLevels, error) {
+	ml := &moduleLevels{spec: spec, rules: []moduleRule{}}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Errorf("bad module level %q", item)
+		}
+		pattern := strings.TrimSpace(parts[0])
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "bad module pattern %q", pattern)
+		}
+		level, err := ParseLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(ml.rules) == 0 || level > ml.max {
+			ml.max = level
+		}
+		ml.rules = append(ml.rules, moduleRule{pattern: pattern, level: level})
+	}
+	if len(ml.rules) == 0 {
+		return nil, nil
+	}
+	return ml, nil
+}
+
+func (rule moduleRule) matches(sr *SourceRecord) bool {
+	candidates := []string{
+		sr.Package,
+		sr.Package + "/" + sr.FileName,
+		sr.FileName,
+		sr.Function,
+		sr.QualifiedFunction,
+		sr.Package + "." + sr.QualifiedFunction,
+	}
+	for _, name := range candidates {
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (ml *moduleLevels) match(sr *SourceRecord) moduleMatch {
+	for _, rule := range ml.rules {
+		if rule.matches(sr) {
+			return moduleMatch{level: rule.level, ok: true}
+		}
+	}
+	return moduleMatch{}
+}
+
+func (ml *moduleLevels) forSource(sr *SourceRecord) (LogLevel, bool) {
+	if v, ok := ml.srcCache.Load(sr.PC); ok {
+		m := v.(moduleMatch)
+		return m.level, m.ok
+	}
+	m := ml.match(sr)
+	ml.srcCache.Store(sr.PC, m)
+	return m.level, m.ok
+}
+
+func (ml *moduleLevels) forPC(pc uintptr) (LogLevel, bool) {
+	if v, ok := ml.pcCache.Load(pc); ok {
+		m := v.(moduleMatch)
+		return m.level, m.ok
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	m := ml.match(newSourceRecord(frame.PC, frame.File, frame.Line, frame.Function))
+	ml.pcCache.Store(pc, m)
+	return m.level, m.ok
+}
+
+func (l *Logger) WithModuleLevels(spec string) (*Logger, error) {
+	l = l.Clone()
+	err := l.SetModuleLevels(spec)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetModuleLevels overrides the logger's level for matching code, eg.
+// "github.com/us/db/*=DEBUG,handler.go=TRACE".  Patterns are matched with
+// path.Match against the package, file name, package/file, and function
+// names of the caller; the first matching rule wins.  An empty spec
+// removes all overrides.
+func (l *Logger) SetModuleLevels(spec string) error {
+	ml, err := parseModuleLevels(spec)
+	if err != nil {
+		return err
+	}
+	l.update(func(cfg *loggerConfig) { cfg.modules = ml })
+	return nil
+}
+
+func (l *Logger) ModuleLevels() string {
+	ml := l.cfg().modules
+	if ml == nil {
+		return ""
+	}
+	return ml.spec
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+type ModuleSuite struct {}
+var _ = Suite(&ModuleSuite{})
+
+func moduleHelper(l *Logger, msg string) {
+	l.Debugln(msg)
+}
+
+func (a *ModuleSuite) TestParse(c *C) {
+	ml, err := parseModuleLevels(" github.com/us/db/*=DEBUG, handler.go=TRACE ,")
+	c.Assert(err, IsNil)
+	c.Check(ml.rules, DeepEquals, []moduleRule{
+		{pattern: "github.com/us/db/*", level: DEBUG},
+		{pattern: "handler.go", level: TRACE},
+	})
+	c.Check(ml.max, Equals, DEBUG)
+	ml, err = parseModuleLevels("")
+	c.Check(err, IsNil)
+	c.Check(ml, IsNil)
+	_, err = parseModuleLevels("handler.go")
+	c.Check(err, NotNil)
+	_, err = parseModuleLevels("=DEBUG")
+	c.Check(err, NotNil)
+	_, err = parseModuleLevels("[=DEBUG")
+	c.Check(err, NotNil)
+	_, err = parseModuleLevels("handler.go=LOUD")
+	c.Check(err, NotNil)
+}
+
+func (a *ModuleSuite) TestOverrides(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, ERROR)
+	l.SetFlags(0)
+	l.Debugln("hidden")
+	c.Check(l.SetModuleLevels("module_test.go=INFO"), IsNil)
+	c.Check(l.ModuleLevels(), Equals, "module_test.go=INFO")
+	c.Check(l.threshold(), Equals, INFO)
+	l.Infoln("file")
+	l.Debugln("too verbose")
+	c.Check(l.SetModuleLevels("moduleHelper=DEBUG,*=ERROR"), IsNil)
+	l.Debugln("not this function")
+	moduleHelper(l, "helper")
+	c.Check(l.SetModuleLevels("github.com/rclancey/logging=DEBUG"), IsNil)
+	l.Debugf("%s", "package")
+	c.Check(l.SetModuleLevels("*.TestOverrides=DEBUG"), IsNil)
+	l.Debug("method")
+	c.Check(l.SetModuleLevels(""), IsNil)
+	c.Check(l.ModuleLevels(), Equals, "")
+	l.Debugln("hidden again")
+	l.Flush()
+	c.Check(buf.String(), Equals, "INFO     file\nDEBUG    helper\nDEBUG    package\nDEBUG    method\n")
+}
+
+func (a *ModuleSuite) TestSinksKeepLevel(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	sinkBuf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFlags(0)
+	l.AddSink(NewWriterSink(sinkBuf, ERROR, nil, false))
+	c.Check(l.SetModuleLevels("module_test.go=ERROR"), IsNil)
+	l.Infoln("quiet")
+	l.Errorln("loud")
+	l.Flush()
+	c.Check(buf.String(), Equals, "ERROR    loud\n")
+	c.Check(sinkBuf.String(), Equals, "ERROR    loud\n")
+}
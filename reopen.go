@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+type ReopenFile struct {
+	lock sync.Mutex
+	filename string
+	file *os.File
+}
+
+var reopenRegistry = struct {
+	lock sync.Mutex
+	files map[*ReopenFile]bool
+}{files: map[*ReopenFile]bool{}}
+
+func NewReopenFile(filename string) (*ReopenFile, error) {
+	rf := &ReopenFile{filename: filename}
+	err := rf.Reopen()
+	if err != nil {
+		return nil, err
+	}
+	reopenRegistry.lock.Lock()
+	reopenRegistry.files[rf] = true
+	reopenRegistry.lock.Unlock()
+	return rf, nil
+}
+
+func (rf *ReopenFile) Filename() string {
+	return rf.filename
+}
+
+func (rf *ReopenFile) Reopen() error {
+	f, err := os.OpenFile(rf.filename, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "can't open log file %s", rf.filename)
+	}
+	rf.lock.Lock()
+	old := rf.file
+	rf.file = f
+	rf.lock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (rf *ReopenFile) Write(data []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.file == nil {
+		return 0, errors.Errorf("log file %s is closed", rf.filename)
+	}
+	return rf.file.Write(data)
+}
+
+func (rf *ReopenFile) Close() error {
+	reopenRegistry.lock.Lock()
+	delete(reopenRegistry.files, rf)
+	reopenRegistry.lock.Unlock()
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func reopenFiles() []*ReopenFile {
+	reopenRegistry.lock.Lock()
+	defer reopenRegistry.lock.Unlock()
+	files := make([]*ReopenFile, 0, len(reopenRegistry.files))
+	for rf := range reopenRegistry.files {
+		files = append(files, rf)
+	}
+	return files
+}
+
+// ReopenAll reopens every open ReopenFile and writes a LOG line about it
+// to l, or to the default logger if l is nil.
+func ReopenAll(l *Logger) error {
+	if l == nil {
+		l = defaultLogger
+	}
+	var firstErr error
+	for _, rf := range reopenFiles() {
+		err := rf.Reopen()
+		if err != nil {
+			l.RawWrite(withDepth(context.Background(), 1), ERROR, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		l.RawWrite(withDepth(context.Background(), 1), LOG, "reopened log file " + rf.filename)
+	}
+	return firstErr
+}
+
+// ReopenOnSIGHUP calls ReopenAll(l) whenever the process receives a
+// SIGHUP, for use with logrotate's create mode.  Call the returned
+// function to uninstall the handler.
+func ReopenOnSIGHUP(l *Logger) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				ReopenAll(l)
+			case <-done:
+				return
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ReopenSuite struct {}
+var _ = Suite(&ReopenSuite{})
+
+func (a *ReopenSuite) TestReopen(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewReopenFile(fn)
+	c.Assert(err, IsNil)
+	c.Check(rf.Filename(), Equals, fn)
+	l := NewLogger(rf, DEBUG)
+	l.SetFlags(0)
+	l.RawLogSync(nil, INFO, "before")
+	c.Assert(os.Rename(fn, fn + ".1"), IsNil)
+	l.RawLogSync(nil, INFO, "moved")
+	c.Check(ReopenAll(l), IsNil)
+	l.RawLogSync(nil, INFO, "after")
+	c.Check(rf.Close(), IsNil)
+	c.Check(readFile(c, fn + ".1"), Equals, "INFO     before\nINFO     moved\n")
+	c.Check(readFile(c, fn), Equals, "LOG      reopened log file " + fn + "\nINFO     after\n")
+	_, err = rf.Write([]byte("closed\n"))
+	c.Check(err, ErrorMatches, "log file .* is closed")
+	for _, x := range reopenFiles() {
+		c.Check(x, Not(Equals), rf)
+	}
+}
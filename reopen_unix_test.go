@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (a *ReopenSuite) TestSIGHUP(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewReopenFile(fn)
+	c.Assert(err, IsNil)
+	defer rf.Close()
+	l := NewLogger(rf, DEBUG)
+	l.SetFlags(0)
+	stop := ReopenOnSIGHUP(l)
+	defer stop()
+	l.RawLogSync(nil, INFO, "before")
+	c.Assert(os.Rename(fn, fn + ".1"), IsNil)
+	c.Assert(syscall.Kill(os.Getpid(), syscall.SIGHUP), IsNil)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := ioutil.ReadFile(fn)
+		if strings.Contains(string(data), "reopened") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.RawLogSync(nil, INFO, "after")
+	c.Check(readFile(c, fn + ".1"), Equals, "INFO     before\n")
+	c.Check(readFile(c, fn), Equals, "LOG      reopened log file " + fn + "\nINFO     after\n")
+	stop()
+	stop()
+}
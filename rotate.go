@@ -0,0 +1,369 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type RotateSchedule int
+
+const (
+	RotateNever  RotateSchedule = 0
+	RotateHourly RotateSchedule = 1
+	RotateDaily  RotateSchedule = 2
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+type RotatingFile struct {
+	lock sync.Mutex
+	filename string
+	maxSize int64
+	schedule RotateSchedule
+	timeZone *time.Location
+	compress bool
+	maxAge time.Duration
+	maxBackups int
+	file *os.File
+	size int64
+	nextRotation time.Time
+	// rotated backups are compressed and cleaned up one at a time by a
+	// single background goroutine, so cleanup never sees a half-written
+	// .gz file
+	tidyJobs []tidyJob
+	tidying bool
+	background sync.WaitGroup
+	now func() time.Time
+	rename func(oldpath, newpath string) error
+	gzip func(filename string) error
+}
+
+func NewRotatingFile(filename string) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		filename: filename,
+		timeZone: time.Local,
+		now: time.Now,
+		rename: os.Rename,
+		gzip: compressFile,
+	}
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) Filename() string {
+	return rf.filename
+}
+
+func (rf *RotatingFile) SetMaxSize(maxSize int64) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	rf.maxSize = maxSize
+}
+
+func (rf *RotatingFile) MaxSize() int64 {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.maxSize
+}
+
+// SetSchedule rotates the file at the top of every hour or at midnight in
+// the given time zone.  Pass the logger's TimeZone() so file boundaries
+// line up with the timestamps inside the file.
+func (rf *RotatingFile) SetSchedule(schedule RotateSchedule, timeZone *time.Location) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if timeZone == nil {
+		timeZone = time.Local
+	}
+	rf.schedule = schedule
+	rf.timeZone = timeZone
+	rf.nextRotation = rf.computeNextRotation(rf.now())
+}
+
+func (rf *RotatingFile) Schedule() RotateSchedule {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.schedule
+}
+
+func (rf *RotatingFile) SetCompress(compress bool) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	rf.compress = compress
+}
+
+func (rf *RotatingFile) SetMaxAge(maxAge time.Duration) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	rf.maxAge = maxAge
+}
+
+func (rf *RotatingFile) SetMaxBackups(maxBackups int) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	rf.maxBackups = maxBackups
+}
+
+func (rf *RotatingFile) computeNextRotation(now time.Time) time.Time {
+	t := now.In(rf.timeZone)
+	switch rf.schedule {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour() + 1, 0, 0, 0, rf.timeZone)
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day() + 1, 0, 0, 0, 0, rf.timeZone)
+	}
+	return time.Time{}
+}
+
+func (rf *RotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(rf.filename), 0755)
+	if err != nil {
+		return errors.Wrapf(err, "can't create log directory for %s", rf.filename)
+	}
+	f, err := os.OpenFile(rf.filename, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "can't open log file %s", rf.filename)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "can't stat log file %s", rf.filename)
+	}
+	rf.file = f
+	rf.size = st.Size()
+	return nil
+}
+
+func (rf *RotatingFile) Write(data []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.file == nil {
+		return 0, errors.Errorf("log file %s is closed", rf.filename)
+	}
+	now := rf.now()
+	due := !rf.nextRotation.IsZero() && !now.Before(rf.nextRotation)
+	full := rf.maxSize > 0 && rf.size > 0 && rf.size + int64(len(data)) > rf.maxSize
+	if due && rf.size == 0 {
+		rf.nextRotation = rf.computeNextRotation(now)
+		due = false
+	}
+	if due || full {
+		err := rf.rotate(now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(data)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) Rotate() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.rotate(rf.now())
+}
+
+func (rf *RotatingFile) backupName(t time.Time) string {
+	dir := filepath.Dir(rf.filename)
+	ext := filepath.Ext(rf.filename)
+	base := strings.TrimSuffix(filepath.Base(rf.filename), ext)
+	stamp := t.In(rf.timeZone).Format(backupTimeFormat)
+	name := filepath.Join(dir, base + "-" + stamp + ext)
+	for i := 1; ; i++ {
+		_, err := os.Stat(name)
+		_, gzErr := os.Stat(name + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return name
+		}
+		name = filepath.Join(dir, base + "-" + stamp + "." + strconv.Itoa(i) + ext)
+	}
+}
+
+func (rf *RotatingFile) rotate(now time.Time) error {
+	if rf.file != nil {
+		err := rf.file.Close()
+		rf.file = nil
+		if err != nil {
+			return errors.Wrapf(err, "can't close log file %s", rf.filename)
+		}
+	}
+	backup := rf.backupName(now)
+	err := rf.rename(rf.filename, backup)
+	if err != nil && !os.IsNotExist(err) {
+		// keep writing to the current file rather than none at all
+		if oerr := rf.open(); oerr != nil {
+			return oerr
+		}
+		return errors.Wrapf(err, "can't rename log file %s", rf.filename)
+	}
+	if !rf.nextRotation.IsZero() {
+		rf.nextRotation = rf.computeNextRotation(now)
+	}
+	err = rf.open()
+	if err != nil {
+		return err
+	}
+	rf.tidyJobs = append(rf.tidyJobs, tidyJob{
+		backup: backup,
+		filename: rf.filename,
+		timeZone: rf.timeZone,
+		now: now,
+		compress: rf.compress,
+		maxAge: rf.maxAge,
+		maxBackups: rf.maxBackups,
+	})
+	if !rf.tidying {
+		rf.tidying = true
+		rf.background.Add(1)
+		go rf.tidy()
+	}
+	return nil
+}
+
+type tidyJob struct {
+	backup string
+	filename string
+	timeZone *time.Location
+	now time.Time
+	compress bool
+	maxAge time.Duration
+	maxBackups int
+}
+
+func (rf *RotatingFile) tidy() {
+	defer rf.background.Done()
+	for {
+		rf.lock.Lock()
+		if len(rf.tidyJobs) == 0 {
+			rf.tidying = false
+			rf.lock.Unlock()
+			return
+		}
+		job := rf.tidyJobs[0]
+		rf.tidyJobs = rf.tidyJobs[1:]
+		rf.lock.Unlock()
+		if job.compress {
+			rf.gzip(job.backup)
+		}
+		cleanup(job.filename, job.timeZone, job.now, job.maxAge, job.maxBackups)
+	}
+}
+
+func compressFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(filename + ".gz", os.O_WRONLY | os.O_CREATE | os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	return os.Remove(filename)
+}
+
+type backupFile struct {
+	path string
+	stamp time.Time
+	seq int
+}
+
+func backups(filename string, timeZone *time.Location) []backupFile {
+	dir := filepath.Dir(filename)
+	ext := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filepath.Base(filename), ext) + "-"
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, info := range infos {
+		names[info.Name()] = true
+	}
+	backups := []backupFile{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// a .gz next to its uncompressed original is still being
+		// written, or was abandoned part way; the original stands for both
+		if strings.HasSuffix(name, ".gz") && names[strings.TrimSuffix(name, ".gz")] {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		stamp = strings.TrimSuffix(stamp, ext)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], timeZone)
+		if err != nil {
+			continue
+		}
+		// backupName adds .1, .2, ... to later backups with the same stamp
+		seq := 0
+		if suffix := stamp[len(backupTimeFormat):]; suffix != "" {
+			seq, err = strconv.Atoi(strings.TrimPrefix(suffix, "."))
+			if err != nil || !strings.HasPrefix(suffix, ".") {
+				continue
+			}
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), stamp: t, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp.Equal(backups[j].stamp) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].stamp.After(backups[j].stamp)
+	})
+	return backups
+}
+
+func cleanup(filename string, timeZone *time.Location, now time.Time, maxAge time.Duration, maxBackups int) {
+	if maxAge <= 0 && maxBackups <= 0 {
+		return
+	}
+	for i, b := range backups(filename, timeZone) {
+		if (maxBackups > 0 && i >= maxBackups) || (maxAge > 0 && now.Sub(b.stamp) > maxAge) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.lock.Lock()
+	var err error
+	if rf.file != nil {
+		err = rf.file.Close()
+		rf.file = nil
+	}
+	rf.lock.Unlock()
+	rf.background.Wait()
+	return err
+}
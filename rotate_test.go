@@ -0,0 +1,292 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RotateSuite struct {}
+var _ = Suite(&RotateSuite{})
+
+type fakeClock struct {
+	lock sync.Mutex
+	t time.Time
+}
+
+func (fc *fakeClock) now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.t
+}
+
+func (fc *fakeClock) advance(d time.Duration) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.t = fc.t.Add(d)
+}
+
+func listDir(c *C, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(c *C, fn string) string {
+	data, err := ioutil.ReadFile(fn)
+	c.Assert(err, IsNil)
+	return string(data)
+}
+
+func (a *RotateSuite) TestSize(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	clock := &fakeClock{t: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	rf.now = clock.now
+	rf.SetSchedule(RotateNever, time.UTC)
+	rf.SetMaxSize(25)
+	c.Check(rf.MaxSize(), Equals, int64(25))
+	for i := 0; i < 3; i++ {
+		n, err := rf.Write([]byte(fmt.Sprintf("line %d is 19 bytes\n", i)))
+		c.Check(err, IsNil)
+		c.Check(n, Equals, 19)
+		clock.advance(time.Second)
+	}
+	c.Check(rf.Close(), IsNil)
+	c.Check(listDir(c, dir), DeepEquals, []string{
+		"app-2020-01-02T03-04-06.000.log",
+		"app-2020-01-02T03-04-07.000.log",
+		"app.log",
+	})
+	c.Check(readFile(c, filepath.Join(dir, "app-2020-01-02T03-04-06.000.log")), Equals, "line 0 is 19 bytes\n")
+	c.Check(readFile(c, fn), Equals, "line 2 is 19 bytes\n")
+	_, err = rf.Write([]byte("closed\n"))
+	c.Check(err, ErrorMatches, "log file .* is closed")
+}
+
+func (a *RotateSuite) TestSchedule(c *C) {
+	tz, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	clock := &fakeClock{t: time.Date(2020, 1, 2, 23, 30, 0, 0, tz)}
+	rf.now = clock.now
+	rf.SetSchedule(RotateDaily, tz)
+	c.Check(rf.Schedule(), Equals, RotateDaily)
+	rf.Write([]byte("day one\n"))
+	clock.advance(20 * time.Minute)
+	rf.Write([]byte("still day one\n"))
+	clock.advance(20 * time.Minute)
+	rf.Write([]byte("day two\n"))
+	clock.advance(48 * time.Hour)
+	rf.Write([]byte("day four\n"))
+	c.Check(rf.Close(), IsNil)
+	c.Check(listDir(c, dir), DeepEquals, []string{
+		"app-2020-01-03T00-10-00.000.log",
+		"app-2020-01-05T00-10-00.000.log",
+		"app.log",
+	})
+	c.Check(readFile(c, filepath.Join(dir, "app-2020-01-03T00-10-00.000.log")), Equals, "day one\nstill day one\n")
+	c.Check(readFile(c, fn), Equals, "day four\n")
+}
+
+func (a *RotateSuite) TestHourly(c *C) {
+	rf := &RotatingFile{timeZone: time.UTC, schedule: RotateHourly}
+	next := rf.computeNextRotation(time.Date(2020, 1, 2, 23, 30, 0, 0, time.UTC))
+	c.Check(next, Equals, time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC))
+}
+
+func (a *RotateSuite) TestCompressAndRetention(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	clock := &fakeClock{t: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	rf.now = clock.now
+	rf.SetSchedule(RotateNever, time.UTC)
+	rf.SetCompress(true)
+	rf.SetMaxBackups(2)
+	rf.SetMaxAge(72 * time.Hour)
+	ioutil.WriteFile(filepath.Join(dir, "app-2019-01-01T00-00-00.000.log"), []byte("ancient\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "other.log"), []byte("unrelated\n"), 0644)
+	for i := 0; i < 4; i++ {
+		rf.Write([]byte(fmt.Sprintf("day %d\n", i)))
+		c.Check(rf.Rotate(), IsNil)
+		rf.background.Wait()
+		clock.advance(24 * time.Hour)
+	}
+	c.Check(rf.Close(), IsNil)
+	c.Check(listDir(c, dir), DeepEquals, []string{
+		"app-2020-01-04T00-00-00.000.log.gz",
+		"app-2020-01-05T00-00-00.000.log.gz",
+		"app.log",
+		"other.log",
+	})
+	f, err := os.Open(filepath.Join(dir, "app-2020-01-05T00-00-00.000.log.gz"))
+	c.Assert(err, IsNil)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(gz)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "day 3\n")
+}
+
+func (a *RotateSuite) TestSameStampRetention(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	clock := &fakeClock{t: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	rf.now = clock.now
+	rf.SetSchedule(RotateNever, time.UTC)
+	rf.SetMaxBackups(2)
+	for i := 0; i < 3; i++ {
+		rf.Write([]byte(fmt.Sprintf("part %d\n", i)))
+		c.Check(rf.Rotate(), IsNil)
+		rf.background.Wait()
+	}
+	c.Check(rf.Close(), IsNil)
+	c.Check(listDir(c, dir), DeepEquals, []string{
+		"app-2020-01-02T00-00-00.000.1.log",
+		"app-2020-01-02T00-00-00.000.2.log",
+		"app.log",
+	})
+	c.Check(readFile(c, filepath.Join(dir, "app-2020-01-02T00-00-00.000.2.log")), Equals, "part 2\n")
+}
+
+func (a *RotateSuite) TestRenameFailure(c *C) {
+	fn := filepath.Join(c.MkDir(), "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	rf.SetMaxSize(10)
+	rf.rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrPermission}
+	}
+	_, err = rf.Write([]byte("0123456789"))
+	c.Check(err, IsNil)
+	_, err = rf.Write([]byte("overflow\n"))
+	c.Check(err, ErrorMatches, `can't rename log file .*permission denied`)
+	rf.rename = os.Rename
+	_, err = rf.Write([]byte("recovered\n"))
+	c.Check(err, IsNil)
+	c.Check(rf.Close(), IsNil)
+	c.Check(readFile(c, fn), Equals, "recovered\n")
+}
+
+func (a *RotateSuite) TestConcurrentAsync(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	rf.SetMaxSize(1000)
+	l := NewLogger(rf, DEBUG)
+	l.SetFlags(0)
+	extra := NewLogger(rf, DEBUG)
+	extra.SetFlags(0)
+	wg := &sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l.Infof("goroutine %d line %d", g, i)
+				extra.Warnf("goroutine %d line %d", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	l.Flush()
+	extra.Flush()
+	c.Check(rf.Close(), IsNil)
+	total := 0
+	for _, name := range listDir(c, dir) {
+		data := readFile(c, filepath.Join(dir, name))
+		c.Check(len(data) <= 1000, Equals, true)
+		for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+			c.Check(line, Matches, `(INFO     |WARNING  )goroutine [0-9] line [0-9]+`)
+			total += 1
+		}
+	}
+	c.Check(total, Equals, 800)
+}
+
+func (a *RotateSuite) TestBackupsSkipPartialGzip(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	for _, name := range []string{
+		"app-2020-01-02T00-00-00.000.log",
+		"app-2020-01-02T00-00-00.000.log.gz",
+		"app-2020-01-01T00-00-00.000.log.gz",
+	} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644)
+	}
+	names := []string{}
+	for _, b := range backups(fn, time.UTC) {
+		names = append(names, filepath.Base(b.path))
+	}
+	c.Check(names, DeepEquals, []string{
+		"app-2020-01-02T00-00-00.000.log",
+		"app-2020-01-01T00-00-00.000.log.gz",
+	})
+}
+
+func (a *RotateSuite) TestCleanupWaitsForCompression(c *C) {
+	dir := c.MkDir()
+	fn := filepath.Join(dir, "app.log")
+	rf, err := NewRotatingFile(fn)
+	c.Assert(err, IsNil)
+	clock := &fakeClock{t: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	rf.now = clock.now
+	rf.SetSchedule(RotateNever, time.UTC)
+	rf.SetCompress(true)
+	rf.SetMaxBackups(1)
+	started := make(chan string, 2)
+	release := make(chan bool)
+	calls := 0
+	rf.gzip = func(filename string) error {
+		calls++
+		started <- filepath.Base(filename)
+		if calls == 1 {
+			<-release
+		}
+		return compressFile(filename)
+	}
+	rf.Write([]byte("first\n"))
+	c.Check(rf.Rotate(), IsNil)
+	c.Check(<-started, Equals, "app-2020-01-02T00-00-00.000.log")
+	clock.advance(time.Second)
+	rf.Write([]byte("second\n"))
+	c.Check(rf.Rotate(), IsNil)
+	time.Sleep(100 * time.Millisecond)
+	// the second backup waits its turn, leaving the first one alone
+	c.Check(listDir(c, dir), DeepEquals, []string{
+		"app-2020-01-02T00-00-00.000.log",
+		"app-2020-01-02T00-00-01.000.log",
+		"app.log",
+	})
+	close(release)
+	c.Check(rf.Close(), IsNil)
+	c.Check(<-started, Equals, "app-2020-01-02T00-00-01.000.log")
+	c.Check(listDir(c, dir), DeepEquals, []string{
+		"app-2020-01-02T00-00-01.000.log.gz",
+		"app.log",
+	})
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+type Sink interface {
+	Level() LogLevel
+	Log(rec *Record) error
+}
+
+type WriterSink struct {
+	level int32
+	w io.Writer
+	out *lockedWriter
+	formatter Formatter
+	colorize bool
+}
+
+func NewWriterSink(w io.Writer, level LogLevel, formatter Formatter, colorize bool) *WriterSink {
+	if formatter == nil {
+		formatter = NewTextFormatter()
+	}
+	return &WriterSink{
+		level: int32(level),
+		w: w,
+		out: &lockedWriter{w: w},
+		formatter: formatter,
+		colorize: colorize,
+	}
+}
+
+func (s *WriterSink) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&s.level, int32(level))
+}
+
+func (s *WriterSink) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&s.level))
+}
+
+func (s *WriterSink) Writer() io.Writer {
+	return s.w
+}
+
+func (s *WriterSink) Formatter() Formatter {
+	return s.formatter
+}
+
+func (s *WriterSink) Colorize() bool {
+	return s.colorize
+}
+
+func (s *WriterSink) Log(rec *Record) error {
+	if rec.Level > s.Level() {
+		return nil
+	}
+	r := *rec
+	r.Colorize = s.colorize
+	data, err := s.formatter.Format(&r)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(data)
+	return err
+}
+
+type RingBuffer struct {
+	lock sync.Mutex
+	lines []string
+	next int
+	full bool
+}
+
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &RingBuffer{lines: make([]string, size)}
+}
+
+func (rb *RingBuffer) Write(data []byte) (int, error) {
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	rb.lines[rb.next] = string(data)
+	rb.next += 1
+	if rb.next == len(rb.lines) {
+		rb.next = 0
+		rb.full = true
+	}
+	return len(data), nil
+}
+
+func (rb *RingBuffer) Lines() []string {
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	if !rb.full {
+		return append([]string{}, rb.lines[:rb.next]...)
+	}
+	out := make([]string, 0, len(rb.lines))
+	out = append(out, rb.lines[rb.next:]...)
+	return append(out, rb.lines[:rb.next]...)
+}
+
+func (rb *RingBuffer) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, line := range rb.Lines() {
+		n, err := io.WriteString(w, line)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SinkSuite struct {}
+var _ = Suite(&SinkSuite{})
+
+func (a *SinkSuite) TestFanOut(c *C) {
+	stderr := bytes.NewBuffer([]byte{})
+	jsonFile := bytes.NewBuffer([]byte{})
+	ring := NewRingBuffer(3)
+	l := NewLogger(stderr, ERROR)
+	l.SetFlags(0)
+	l.Colorize()
+	jsonSink := NewWriterSink(jsonFile, INFO, NewJSONFormatter(), true)
+	ringSink := NewWriterSink(ring, DEBUG, nil, false)
+	l.AddSink(jsonSink)
+	l.AddSink(ringSink)
+	c.Check(l.Sinks(), DeepEquals, []Sink{jsonSink, ringSink})
+	c.Check(l.threshold(), Equals, DEBUG)
+	l.RawLogSync(nil, DEBUG, "debug")
+	l.RawLogSync(nil, INFO, "info")
+	l.RawLogSync(nil, ERROR, "error")
+	c.Check(stderr.String(), Equals, "\033[31;49mERROR   \033[0m \033[31;49merror\033[0m\n")
+	lines := strings.Split(strings.TrimSpace(jsonFile.String()), "\n")
+	c.Assert(lines, HasLen, 2)
+	c.Check(strings.Contains(jsonFile.String(), "\033"), Equals, false)
+	obj := map[string]interface{}{}
+	c.Check(json.Unmarshal([]byte(lines[0]), &obj), IsNil)
+	c.Check(obj["msg"], Equals, "info")
+	c.Check(ring.Lines(), DeepEquals, []string{
+		"DEBUG    debug\n",
+		"INFO     info\n",
+		"ERROR    error\n",
+	})
+	l.RawLogSync(nil, WARNING, "warning")
+	c.Check(ring.Lines(), DeepEquals, []string{
+		"INFO     info\n",
+		"ERROR    error\n",
+		"WARNING  warning\n",
+	})
+	l.RemoveSink(ringSink)
+	c.Check(l.Sinks(), DeepEquals, []Sink{jsonSink})
+	c.Check(l.threshold(), Equals, INFO)
+	jsonSink.SetLevel(ERROR)
+	c.Check(l.threshold(), Equals, ERROR)
+}
+
+func (a *SinkSuite) TestNoPrimaryOutput(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(nil, ERROR)
+	l.SetFlags(0)
+	l2 := l.WithSink(NewWriterSink(buf, INFO, nil, false))
+	c.Check(l.Sinks(), HasLen, 0)
+	l2.Info("async")
+	l2.Flush()
+	c.Check(buf.String(), Equals, "INFO     async\n")
+	buf.Reset()
+	l2.Debug("skipped")
+	l2.Flush()
+	c.Check(buf.String(), Equals, "")
+}
+
+func (a *SinkSuite) TestRingBuffer(c *C) {
+	rb := NewRingBuffer(2)
+	c.Check(rb.Lines(), HasLen, 0)
+	rb.Write([]byte("a\n"))
+	c.Check(rb.Lines(), DeepEquals, []string{"a\n"})
+	rb.Write([]byte("b\n"))
+	rb.Write([]byte("c\n"))
+	out := bytes.NewBuffer([]byte{})
+	n, err := rb.WriteTo(out)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, int64(4))
+	c.Check(out.String(), Equals, "b\nc\n")
+}
@@ -0,0 +1,201 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	SlogLevelTrace    = slog.Level(-2)
+	SlogLevelCritical = slog.Level(12)
+)
+
+// LevelFromSlog maps a slog level to the least verbose LogLevel that
+// includes it.
+func LevelFromSlog(level slog.Level) LogLevel {
+	switch {
+	case level >= SlogLevelCritical:
+		return CRITICAL
+	case level >= slog.LevelError:
+		return ERROR
+	case level >= slog.LevelWarn:
+		return WARNING
+	case level >= slog.LevelInfo:
+		return INFO
+	case level >= SlogLevelTrace:
+		return TRACE
+	}
+	return DEBUG
+}
+
+// SlogLevel maps a LogLevel to a slog level.  Custom levels map to the
+// slog level of the next less verbose built-in level.  LOG and NONE, which
+// are for messages that always show rather than severe ones, map to
+// slog.LevelInfo.
+func SlogLevel(level LogLevel) slog.Level {
+	switch {
+	case level <= LOG:
+		return slog.LevelInfo
+	case level <= CRITICAL:
+		return SlogLevelCritical
+	case level <= ERROR:
+		return slog.LevelError
+	case level <= WARNING:
+		return slog.LevelWarn
+	case level <= INFO:
+		return slog.LevelInfo
+	case level <= TRACE:
+		return SlogLevelTrace
+	}
+	return slog.LevelDebug
+}
+
+// SlogHandler is a slog.Handler that writes through a Logger.  Attributes
+// become fields, with group names joined to keys by dots.
+type SlogHandler struct {
+	l *Logger
+	groups []string
+	attrs Fields
+}
+
+// NewSlogHandler creates a handler writing to l, or the default logger if
+// l is nil.
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{l: l}
+}
+
+func (h *SlogHandler) getLogger() *Logger {
+	if h.l == nil {
+		return defaultLogger
+	}
+	return h.l
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.getLogger().Enabled(LevelFromSlog(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	l := h.getLogger()
+	var sr *SourceRecord
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		sr = newSourceRecord(frame.PC, frame.File, frame.Line, frame.Function)
+	}
+	rec := l.NewRecord(ctx, LevelFromSlog(r.Level), sr, r.Message)
+	if !l.recordAllowed(rec) {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		rec.Time = r.Time.In(rec.Time.Location())
+	}
+	fields := h.attrs
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.groups, a)
+		return true
+	})
+	rec.Fields = rec.Fields.with(fields...)
+	l.writeAsync(rec)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(Fields{}, h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendSlogAttr(h2.attrs, h.groups, a)
+	}
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(append([]string{}, h.groups...), name)
+	return &h2
+}
+
+func appendSlogAttr(fields Fields, groups []string, a slog.Attr) Fields {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(append([]string{}, groups...), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendSlogAttr(fields, groups, ga)
+		}
+		return fields
+	}
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	return fields.with(Field{Key: key, Value: a.Value.Any()})
+}
+
+// SlogSink forwards log records to a slog.Handler.
+type SlogSink struct {
+	level int32
+	handler slog.Handler
+}
+
+func NewSlogSink(handler slog.Handler, level LogLevel) *SlogSink {
+	return &SlogSink{level: int32(level), handler: handler}
+}
+
+func (s *SlogSink) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&s.level, int32(level))
+}
+
+func (s *SlogSink) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&s.level))
+}
+
+func (s *SlogSink) Handler() slog.Handler {
+	return s.handler
+}
+
+func (s *SlogSink) Log(rec *Record) error {
+	if rec.Level > s.Level() {
+		return nil
+	}
+	ctx := context.Background()
+	level := SlogLevel(rec.Level)
+	if !s.handler.Enabled(ctx, level) {
+		return nil
+	}
+	var pc uintptr
+	if rec.Source != nil {
+		pc = rec.Source.PC
+	}
+	r := slog.NewRecord(rec.Time, level, strings.TrimSuffix(rec.Message, "\n"), pc)
+	if rec.Prefix != "" {
+		r.AddAttrs(slog.String("prefix", rec.Prefix))
+	}
+	if rec.Trace != nil {
+		for _, f := range rec.Trace.ids() {
+			r.AddAttrs(slog.String(f.Key, f.ValueString()))
+		}
+		if rec.Trace.Duration != 0 {
+			r.AddAttrs(slog.Duration("duration", rec.Trace.Duration))
+		}
+	}
+	for _, f := range rec.Fields {
+		r.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+	return s.handler.Handle(ctx, r)
+}
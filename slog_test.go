@@ -0,0 +1,103 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SlogSuite struct {}
+var _ = Suite(&SlogSuite{})
+
+func (a *SlogSuite) TestLevels(c *C) {
+	exp := map[slog.Level]LogLevel{
+		slog.LevelDebug - 4: DEBUG,
+		slog.LevelDebug: DEBUG,
+		SlogLevelTrace: TRACE,
+		slog.LevelInfo: INFO,
+		slog.LevelInfo + 2: INFO,
+		slog.LevelWarn: WARNING,
+		slog.LevelError: ERROR,
+		SlogLevelCritical: CRITICAL,
+		SlogLevelCritical + 4: CRITICAL,
+	}
+	for k, v := range exp {
+		c.Check(LevelFromSlog(k), Equals, v, Commentf("%s", k))
+	}
+	c.Check(SlogLevel(DEBUG), Equals, slog.LevelDebug)
+	c.Check(SlogLevel(TRACE), Equals, SlogLevelTrace)
+	c.Check(SlogLevel(INFO), Equals, slog.LevelInfo)
+	c.Check(SlogLevel(LogLevel(45)), Equals, slog.LevelInfo)
+	c.Check(SlogLevel(WARNING), Equals, slog.LevelWarn)
+	c.Check(SlogLevel(ERROR), Equals, slog.LevelError)
+	c.Check(SlogLevel(CRITICAL), Equals, SlogLevelCritical)
+	c.Check(SlogLevel(LogLevel(15)), Equals, SlogLevelCritical)
+	c.Check(SlogLevel(LOG), Equals, slog.LevelInfo)
+	c.Check(SlogLevel(LogLevel(5)), Equals, slog.LevelInfo)
+	c.Check(SlogLevel(NONE), Equals, slog.LevelInfo)
+}
+
+func (a *SlogSuite) TestHandler(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, INFO)
+	l.SetFlags(0)
+	l.SetSourceFormat("%{filename}:")
+	l.SetPrefix("[app]")
+	h := NewSlogHandler(l)
+	c.Check(h.Enabled(nil, slog.LevelInfo), Equals, true)
+	c.Check(h.Enabled(nil, SlogLevelTrace), Equals, false)
+	sl := slog.New(h)
+	sl.Debug("hidden")
+	sl.Info("hello", "a", 1, slog.Group("g", "b", "two words"))
+	sl.With("req", 7).WithGroup("db").With("table", "users").Warn("slow", "ms", 250)
+	sl.WithGroup("empty").Error("failed", slog.Group("", "inline", true))
+	sl.Log(nil, SlogLevelCritical, "down")
+	l.Flush()
+	c.Check(buf.String(), Equals, "" +
+		"INFO     [app] slog_test.go: hello a=1 g.b=\"two words\"\n" +
+		"WARNING  [app] slog_test.go: slow req=7 db.table=users db.ms=250\n" +
+		"ERROR    [app] slog_test.go: failed empty.inline=true\n" +
+		"CRITICAL [app] slog_test.go: down\n")
+}
+
+func (a *SlogSuite) TestSink(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	jh := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true})
+	l := NewLogger(nil, INFO)
+	l.SetPrefix("[app]")
+	sink := NewSlogSink(jh, TRACE)
+	c.Check(sink.Handler(), Equals, jh)
+	l.AddSink(sink)
+	l.WithField("user", "bob").Warnln("careful")
+	l.Debugln("hidden")
+	l.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 1)
+	obj := map[string]interface{}{}
+	c.Assert(json.Unmarshal([]byte(lines[0]), &obj), IsNil)
+	c.Check(obj["level"], Equals, "WARN")
+	c.Check(obj["msg"], Equals, "careful")
+	c.Check(obj["prefix"], Equals, "[app]")
+	c.Check(obj["user"], Equals, "bob")
+	src, _ := obj["source"].(map[string]interface{})
+	c.Assert(src, NotNil)
+	c.Check(src["file"], Matches, `.*/slog_test\.go`)
+}
+
+func (a *SlogSuite) TestSinkLogLevel(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	jh := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	l := NewLogger(nil, INFO)
+	l.AddSink(NewSlogSink(jh, INFO))
+	l.RawLogSync(nil, LOG, "always")
+	obj := map[string]interface{}{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &obj), IsNil)
+	c.Check(obj["level"], Equals, "INFO")
+	c.Check(obj["msg"], Equals, "always")
+}
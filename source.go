@@ -25,14 +25,16 @@ func NewSourceRecord(skip int) *SourceRecord {
 	if !ok {
 		return nil
 	}
+	return newSourceRecord(pc, fn, ln, runtime.FuncForPC(pc).Name())
+}
+
+func newSourceRecord(pc uintptr, fn string, ln int, name string) *SourceRecord {
 	sr := &SourceRecord{
 		PC: pc,
 		FullPath: fn,
 		FileName: filepath.Base(fn),
 		LineNumber: ln,
 	}
-	fnc := runtime.FuncForPC(pc)
-	name := fnc.Name()
 	pkgpath := strings.Split(name, "/")
 	fname := strings.Split(pkgpath[len(pkgpath) - 1], ".")
 	pkgpath[len(pkgpath) - 1] = fname[0]
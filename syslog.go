@@ -0,0 +1,415 @@
+package logging
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SyslogFacility int
+
+const (
+	FacilityKern     SyslogFacility = 0
+	FacilityUser     SyslogFacility = 1
+	FacilityMail     SyslogFacility = 2
+	FacilityDaemon   SyslogFacility = 3
+	FacilityAuth     SyslogFacility = 4
+	FacilitySyslog   SyslogFacility = 5
+	FacilityLPR      SyslogFacility = 6
+	FacilityNews     SyslogFacility = 7
+	FacilityUUCP     SyslogFacility = 8
+	FacilityCron     SyslogFacility = 9
+	FacilityAuthPriv SyslogFacility = 10
+	FacilityFTP      SyslogFacility = 11
+	FacilityLocal0   SyslogFacility = 16
+	FacilityLocal1   SyslogFacility = 17
+	FacilityLocal2   SyslogFacility = 18
+	FacilityLocal3   SyslogFacility = 19
+	FacilityLocal4   SyslogFacility = 20
+	FacilityLocal5   SyslogFacility = 21
+	FacilityLocal6   SyslogFacility = 22
+	FacilityLocal7   SyslogFacility = 23
+)
+
+type SyslogFormat int
+
+const (
+	RFC5424 SyslogFormat = 0
+	RFC3164 SyslogFormat = 1
+)
+
+const (
+	severityEmerg   = 0
+	severityAlert   = 1
+	severityCrit    = 2
+	severityErr     = 3
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+	severityDebug   = 7
+)
+
+// private enterprise number used for structured data IDs
+const syslogPEN = "32473"
+
+// DefaultSyslogTimeout limits how long a SyslogSink waits to connect or
+// write before giving up on a message.
+const DefaultSyslogTimeout = 5 * time.Second
+
+// after a failed connection, a SyslogSink waits this long before trying
+// again, doubling each time up to the maximum
+const (
+	syslogMinBackoff = 100 * time.Millisecond
+	syslogMaxBackoff = time.Minute
+)
+
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+func syslogSeverity(level LogLevel) int {
+	switch level {
+	case CRITICAL:
+		return severityCrit
+	case ERROR:
+		return severityErr
+	case WARNING:
+		return severityWarning
+	case INFO:
+		return severityInfo
+	case TRACE, DEBUG:
+		return severityDebug
+	}
+	// custom levels take the severity of the next less verbose built-in
+	// level, or notice between WARNING and INFO
+	switch {
+	case level <= LOG:
+		return severityNotice
+	case level < CRITICAL:
+		return severityCrit
+	case level < ERROR:
+		return severityErr
+	case level < WARNING:
+		return severityWarning
+	case level < INFO:
+		return severityNotice
+	case level < TRACE:
+		return severityInfo
+	}
+	return severityDebug
+}
+
+type SyslogSink struct {
+	lock sync.Mutex
+	level int32
+	network string
+	addr string
+	facility SyslogFacility
+	appName string
+	hostname string
+	format SyslogFormat
+	conn net.Conn
+	connNetwork string
+	timeout time.Duration
+	backoff time.Duration
+	retryAt time.Time
+	now func() time.Time
+}
+
+// NewSyslogSink connects to a syslog daemon.  If network is empty, the
+// local daemon is found on one of the usual unix socket paths.
+func NewSyslogSink(network, addr string, level LogLevel) (*SyslogSink, error) {
+	s := &SyslogSink{
+		level: int32(level),
+		network: network,
+		addr: addr,
+		facility: FacilityUser,
+		appName: filepath.Base(os.Args[0]),
+		hostname: getHostname(),
+		format: RFC5424,
+		timeout: DefaultSyslogTimeout,
+		now: time.Now,
+	}
+	err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&s.level, int32(level))
+}
+
+func (s *SyslogSink) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&s.level))
+}
+
+func (s *SyslogSink) SetFacility(facility SyslogFacility) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.facility = facility
+}
+
+func (s *SyslogSink) Facility() SyslogFacility {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.facility
+}
+
+func (s *SyslogSink) SetAppName(appName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.appName = appName
+}
+
+func (s *SyslogSink) AppName() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.appName
+}
+
+func (s *SyslogSink) SetHostname(hostname string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.hostname = hostname
+}
+
+func (s *SyslogSink) SetFormat(format SyslogFormat) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.format = format
+}
+
+func (s *SyslogSink) Format() SyslogFormat {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.format
+}
+
+// SetTimeout sets how long to wait to connect to the daemon or to write a
+// message to it.
+func (s *SyslogSink) SetTimeout(timeout time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.timeout = timeout
+}
+
+func (s *SyslogSink) Timeout() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.timeout
+}
+
+// reconnect connects unless a recent attempt failed, so that a missing
+// daemon doesn't hold up every message
+func (s *SyslogSink) reconnect() error {
+	now := s.now()
+	if now.Before(s.retryAt) {
+		return errors.Errorf("syslog unavailable, retrying in %s", s.retryAt.Sub(now))
+	}
+	err := s.connect()
+	if err != nil {
+		if s.backoff == 0 {
+			s.backoff = syslogMinBackoff
+		} else if s.backoff < syslogMaxBackoff {
+			s.backoff *= 2
+			if s.backoff > syslogMaxBackoff {
+				s.backoff = syslogMaxBackoff
+			}
+		}
+		s.retryAt = now.Add(s.backoff)
+		return err
+	}
+	s.backoff = 0
+	s.retryAt = time.Time{}
+	return nil
+}
+
+func (s *SyslogSink) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.network != "" {
+		conn, err := net.DialTimeout(s.network, s.addr, s.timeout)
+		if err != nil {
+			return errors.Wrapf(err, "can't connect to syslog at %s %s", s.network, s.addr)
+		}
+		s.conn = conn
+		s.connNetwork = s.network
+		return nil
+	}
+	paths := localSyslogPaths
+	if s.addr != "" {
+		paths = []string{s.addr}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, path, s.timeout)
+			if err == nil {
+				s.conn = conn
+				s.connNetwork = network
+				return nil
+			}
+		}
+	}
+	return errors.New("can't connect to local syslog")
+}
+
+func (s *SyslogSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SyslogSink) stream() bool {
+	switch s.connNetwork {
+	case "unixgram", "udp", "udp4", "udp6":
+		return false
+	}
+	return true
+}
+
+func (s *SyslogSink) Log(rec *Record) error {
+	if rec.Level > s.Level() {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var msg string
+	if s.format == RFC3164 {
+		msg = s.format3164(rec)
+	} else {
+		msg = s.format5424(rec)
+	}
+	// a connection that has gone stale since the last message gets one
+	// redial; a fresh one that can't be written to, or a write that timed
+	// out, doesn't
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			err = s.reconnect()
+			if err != nil {
+				return err
+			}
+			attempt++
+		}
+		data := msg
+		if s.stream() {
+			if s.format == RFC3164 {
+				data = msg + "\n"
+			} else {
+				data = strconv.Itoa(len(msg)) + " " + msg
+			}
+		}
+		if s.timeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		}
+		_, err = s.conn.Write([]byte(data))
+		if err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			break
+		}
+	}
+	return err
+}
+
+func (s *SyslogSink) priority(level LogLevel) int {
+	return int(s.facility) * 8 + syslogSeverity(level)
+}
+
+func syslogName(s string, maxLen int) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(out) < maxLen; i++ {
+		c := s[i]
+		if c > 32 && c < 127 && c != '=' && c != ']' && c != '"' {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return "-"
+	}
+	return string(out)
+}
+
+func syslogParamValue(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return r.Replace(s)
+}
+
+func (s *SyslogSink) structuredData(rec *Record) string {
+	sd := ""
+	if rec.Trace != nil {
+		sd += "[trace@" + syslogPEN
+		if rec.Trace.TraceID != "" {
+			sd += ` trace="` + syslogParamValue(rec.Trace.TraceID) + `"`
+		}
+		if rec.Trace.ParentID != "" {
+			sd += ` parent="` + syslogParamValue(rec.Trace.ParentID) + `"`
+		}
+		sd += ` id="` + syslogParamValue(rec.Trace.ID) + `"`
+		if rec.Trace.Duration != 0 {
+			sd += ` duration="` + syslogParamValue(rec.Trace.Duration.String()) + `"`
+		}
+		sd += "]"
+	}
+	if len(rec.Fields) > 0 {
+		sd += "[fields@" + syslogPEN
+		for _, f := range rec.Fields {
+			sd += " " + syslogName(f.Key, 32) + `="` + syslogParamValue(f.ValueString()) + `"`
+		}
+		sd += "]"
+	}
+	if sd == "" {
+		return "-"
+	}
+	return sd
+}
+
+func (s *SyslogSink) format5424(rec *Record) string {
+	msgid := "-"
+	if rec.Prefix != "" {
+		msgid = syslogName(rec.Prefix, 32)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.priority(rec.Level),
+		rec.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogName(s.hostname, 255),
+		syslogName(s.appName, 48),
+		os.Getpid(),
+		msgid,
+		s.structuredData(rec),
+		strings.TrimSpace(rec.Message),
+	)
+}
+
+func (s *SyslogSink) format3164(rec *Record) string {
+	msg := strings.TrimSpace(rec.Message)
+	if len(rec.Fields) > 0 {
+		msg += " " + rec.Fields.String()
+	}
+	return fmt.Sprintf("<%d>%s %s %s[%d]: %s",
+		s.priority(rec.Level),
+		rec.Time.Format(time.Stamp),
+		syslogName(s.hostname, 255),
+		syslogName(s.appName, 32),
+		os.Getpid(),
+		msg,
+	)
+}
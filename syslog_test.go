@@ -0,0 +1,163 @@
+//go:build !windows
+// +build !windows
+
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type SyslogSuite struct {}
+var _ = Suite(&SyslogSuite{})
+
+func readDatagram(c *C, conn *net.UnixConn) string {
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	c.Assert(err, IsNil)
+	return string(buf[:n])
+}
+
+func listenUnixgram(c *C, path string) *net.UnixConn {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	c.Assert(err, IsNil)
+	return conn
+}
+
+func (a *SyslogSuite) TestSeverity(c *C) {
+	c.Check(syslogSeverity(CRITICAL), Equals, 2)
+	c.Check(syslogSeverity(ERROR), Equals, 3)
+	c.Check(syslogSeverity(WARNING), Equals, 4)
+	c.Check(syslogSeverity(INFO), Equals, 6)
+	c.Check(syslogSeverity(TRACE), Equals, 7)
+	c.Check(syslogSeverity(DEBUG), Equals, 7)
+	c.Check(syslogSeverity(LOG), Equals, 5)
+	c.Check(syslogSeverity(NONE), Equals, 5)
+}
+
+func (a *SyslogSuite) TestRFC5424(c *C) {
+	path := filepath.Join(c.MkDir(), "log")
+	server := listenUnixgram(c, path)
+	defer server.Close()
+	s, err := NewSyslogSink("", path, INFO)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	s.SetFacility(FacilityLocal3)
+	s.SetAppName("unittest")
+	s.SetHostname("myhost")
+	c.Check(s.Facility(), Equals, FacilityLocal3)
+	c.Check(s.AppName(), Equals, "unittest")
+	c.Check(s.Format(), Equals, RFC5424)
+	l := NewLogger(nil, NONE).WithSink(s).WithPrefix("db").WithField("user", `bob "the" builder`)
+	l.RawLogSync(nil, DEBUG, "skipped")
+	l.RawLogSync(nil, WARNING, "hello\n")
+	pid := os.Getpid()
+	c.Check(readDatagram(c, server), Matches, fmt.Sprintf(`^<156>1 [0-9T:.+-Z]+ myhost unittest %d db \[fields@32473 user="bob \\"the\\" builder"\] hello$`, pid))
+	l.RawWrite(nil, INFO, "traced", "AAAA BBBB 01.500000s")
+	c.Check(readDatagram(c, server), Matches, `^<158>1 .* db \[trace@32473 parent="AAAA" id="BBBB" duration="1.5s"\]\[fields@32473 .*\] traced$`)
+}
+
+func (a *SyslogSuite) TestRFC3164(c *C) {
+	path := filepath.Join(c.MkDir(), "log")
+	server := listenUnixgram(c, path)
+	defer server.Close()
+	s, err := NewSyslogSink("unixgram", path, DEBUG)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	s.SetFormat(RFC3164)
+	s.SetAppName("unittest")
+	s.SetHostname("myhost")
+	l := NewLogger(nil, NONE).WithSink(s).WithField("k", "v")
+	l.RawLogSync(nil, CRITICAL, "boom")
+	c.Check(readDatagram(c, server), Matches, fmt.Sprintf(`^<10>[A-Z][a-z]{2} [ 0-9]{2} [0-9:]{8} myhost unittest\[%d\]: boom k=v$`, os.Getpid()))
+}
+
+func (a *SyslogSuite) TestReconnect(c *C) {
+	path := filepath.Join(c.MkDir(), "log")
+	server := listenUnixgram(c, path)
+	s, err := NewSyslogSink("unixgram", path, DEBUG)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	c.Check(s.Log(&Record{Level: INFO, Message: "one"}), IsNil)
+	c.Check(readDatagram(c, server), Matches, `^<14>1 .* one$`)
+	server.Close()
+	os.Remove(path)
+	server = listenUnixgram(c, path)
+	defer server.Close()
+	c.Check(s.Log(&Record{Level: INFO, Message: "two"}), IsNil)
+	c.Check(readDatagram(c, server), Matches, `^<14>1 .* two$`)
+}
+
+func (a *SyslogSuite) TestBackoff(c *C) {
+	path := filepath.Join(c.MkDir(), "log")
+	server := listenUnixgram(c, path)
+	s, err := NewSyslogSink("unixgram", path, DEBUG)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	clock := &fakeClock{t: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	s.now = clock.now
+	server.Close()
+	os.Remove(path)
+	c.Check(s.Log(&Record{Level: INFO, Message: "lost"}), NotNil)
+	server = listenUnixgram(c, path)
+	defer server.Close()
+	c.Check(s.Log(&Record{Level: INFO, Message: "early"}), ErrorMatches, "syslog unavailable, retrying in 100ms")
+	clock.advance(100 * time.Millisecond)
+	c.Check(s.Log(&Record{Level: INFO, Message: "on time"}), IsNil)
+	c.Check(readDatagram(c, server), Matches, `^<14>1 .* on time$`)
+}
+
+func (a *SyslogSuite) TestWriteTimeout(c *C) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	s, err := NewSyslogSink("tcp", ln.Addr().String(), DEBUG)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	c.Check(s.Timeout(), Equals, DefaultSyslogTimeout)
+	s.SetTimeout(50 * time.Millisecond)
+	conn, err := ln.Accept()
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	// nobody reads, so writes block once the socket buffers fill up
+	big := strings.Repeat("x", 1 << 20)
+	start := time.Now()
+	for i := 0; i < 64; i++ {
+		err = s.Log(&Record{Level: ERROR, Message: big})
+		if err != nil {
+			break
+		}
+	}
+	c.Check(err, NotNil)
+	c.Check(time.Since(start) < 5 * time.Second, Equals, true)
+}
+
+func (a *SyslogSuite) TestTCP(c *C) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer ln.Close()
+	s, err := NewSyslogSink("tcp", ln.Addr().String(), DEBUG)
+	c.Assert(err, IsNil)
+	defer s.Close()
+	conn, err := ln.Accept()
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	c.Check(s.Log(&Record{Level: ERROR, Message: "framed"}), IsNil)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	var n int
+	_, err = fmt.Fscanf(r, "%d ", &n)
+	c.Assert(err, IsNil)
+	buf := make([]byte, n)
+	_, err = r.Read(buf)
+	c.Check(err, IsNil)
+	c.Check(string(buf), Matches, `^<11>1 .* framed$`)
+}
@@ -2,137 +2,284 @@ package logging
 
 import (
 	"context"
-	"encoding/base32"
+	crand "crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
-	//"runtime"
-	//"strconv"
-	//"strings"
+	"runtime/debug"
+	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	traceIdKey = ctxKey("traceId")
+	// Deprecated: trace IDs are now 32 hex digits and are never a
+	// placeholder.  Use TraceID to get the current one.
 	DefaultTraceID = "xxxxxxxxxxxxx"
 )
 
+const (
+	TraceFlagSampled = byte(0x01)
+)
+
 type TraceFunc func (ctx context.Context) error
 
-var b32enc = base32.StdEncoding.WithPadding(base32.NoPadding)
+// spanContext identifies the span a context is running under.  Trace IDs
+// are 16 random bytes and span IDs 8, both hex encoded as in the W3C
+// traceparent header.
+type spanContext struct {
+	traceID string
+	spanID string
+	parentID string
+	flags byte
+}
 
-func genId() string {
-	idBytes := make([]byte, 8)
-	n, err := rand.Read(idBytes)
-	if err != nil || n < len(idBytes) {
-		return DefaultTraceID
+func randomHex(n int) string {
+	idBytes := make([]byte, n)
+	_, err := crand.Read(idBytes)
+	if err != nil {
+		rand.Read(idBytes)
 	}
-	return b32enc.EncodeToString(idBytes)
+	return hex.EncodeToString(idBytes)
+}
+
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
 }
 
-func withTraceId(ctx context.Context, id string) context.Context {
+func withSpan(ctx context.Context, sc *spanContext) context.Context {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return context.WithValue(ctx, traceIdKey, id)
+	return context.WithValue(ctx, traceIdKey, sc)
 }
 
-func getTraceId(ctx context.Context) string {
+func getSpan(ctx context.Context) *spanContext {
 	if ctx == nil {
-		return DefaultTraceID
+		return nil
 	}
-	val := ctx.Value(traceIdKey)
-	if val != nil {
-		id, isa := val.(string)
-		if isa {
-			return id
+	sc, _ := ctx.Value(traceIdKey).(*spanContext)
+	return sc
+}
+
+// newChildSpan starts a span under the one in ctx, or a new trace if
+// there is none
+func newChildSpan(ctx context.Context) *spanContext {
+	parent := getSpan(ctx)
+	if parent == nil {
+		return &spanContext{
+			traceID: newTraceID(),
+			spanID: newSpanID(),
+			flags: TraceFlagSampled,
 		}
 	}
-	return DefaultTraceID
+	return &spanContext{
+		traceID: parent.traceID,
+		spanID: newSpanID(),
+		parentID: parent.spanID,
+		flags: parent.flags,
+	}
 }
 
-func (l *Logger) RawTrace(ctx context.Context, fnc TraceFunc, msg string) error {
-	if l.level < TRACE {
-		return fnc(ctx)
+// TraceID returns the ID of the trace that ctx is running under, or an
+// empty string if there is none.
+func TraceID(ctx context.Context) string {
+	if sc := getSpan(ctx); sc != nil {
+		return sc.traceID
 	}
-	parentId := getTraceId(ctx)
-	childId := genId()
-	childCtx := withDepth(withTraceId(ctx, childId), 0)
-	start := time.Now()
-	err := fnc(childCtx)
-	end := time.Now()
-	dur := end.Sub(start).Seconds()
-	trace := fmt.Sprintf("%s %s %09.6fs", parentId, childId, dur)
-	l.RawWrite(deepen(ctx), TRACE, msg, trace)
-	return err
+	return ""
 }
 
-func (l *Logger) Trace(ctx context.Context, fnc TraceFunc, args ...interface{}) error {
-	if l.level < TRACE {
-		return fnc(ctx)
+// SpanID returns the ID of the span that ctx is running under, or an empty
+// string if there is none.
+func SpanID(ctx context.Context) string {
+	if sc := getSpan(ctx); sc != nil {
+		return sc.spanID
 	}
-	msg := fmt.Sprint(args...)
-	return l.RawTrace(deepen(ctx), fnc, msg)
+	return ""
 }
 
-func (l *Logger) Traceln(ctx context.Context, fnc TraceFunc, args ...interface{}) error {
-	if l.level < TRACE {
-		return fnc(ctx)
+// traceInfo describes the trace ctx is running under, if any
+func traceInfo(ctx context.Context) *TraceInfo {
+	sc := getSpan(ctx)
+	if sc == nil {
+		return nil
 	}
-	msg := fmt.Sprintln(args...)
-	return l.RawTrace(deepen(ctx), fnc, msg)
+	return &TraceInfo{TraceID: sc.traceID, ParentID: sc.parentID, ID: sc.spanID}
 }
 
-func (l *Logger) Tracef(ctx context.Context, fnc TraceFunc, format string, args ...interface{}) error {
-	if l.level < TRACE {
-		return fnc(ctx)
-	}
-	msg := fmt.Sprintf(format, args...)
-	return l.RawTrace(deepen(ctx), fnc, msg)
-}
-
-/*
-func Trace(ctx context.Context, name string, f func(ctx context.Context) error, skips... int) error {
-	maxDepth := getMaxDepth(ctx)
-	depth := getDepth(ctx)
-	if maxDepth >= 0 && depth >= maxDepth {
-		return f(ctx)
-	}
-	logger := getLogger(ctx)
-	if logger == nil {
-		return f(ctx)
-	}
-	var xname string
-	skip := 1
-	if len(skips) > 0 {
-		skip += skips[0]
-	}
-	pc, _, ln, ok := runtime.Caller(skip)
-	if ok {
-		fnc := runtime.FuncForPC(pc)
-		pkgpath := strings.Split(fnc.Name(), "/")
-		fname := strings.Split(pkgpath[len(pkgpath) - 1], ".")
-		xname = strings.Join(fname[1:], ".") + ":" + strconv.Itoa(ln)
-	} else {
-		xname = "??"
+// TraceparentFromContext formats the span ctx is running under as a W3C
+// traceparent header, or returns an empty string if there is none.
+func TraceparentFromContext(ctx context.Context) string {
+	sc := getSpan(ctx)
+	if sc == nil {
+		return ""
 	}
-	if name != "" {
-		xname += ":" + name
+	return fmt.Sprintf("00-%s-%s-%02x", sc.traceID, sc.spanID, sc.flags)
+}
+
+func isHexID(s string, n int) bool {
+	if len(s) != n || strings.Trim(s, "0") == "" {
+		return false
 	}
-	parentId := getParentId(ctx)
-	id := genId()
-	begin := getBegin(ctx)
-	myCtx := withDepth(withParentId(ctx, id), depth + 1)
-	start := time.Now()
-	if begin {
-		logger.Infoln("BEGIN   ", id, parentId, xname)
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithTraceparent returns a context running under the remote span
+// described by a W3C traceparent header, so that traces started from it
+// continue the caller's trace.
+func ContextWithTraceparent(ctx context.Context, traceparent string) (context.Context, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 {
+		return ctx, errors.Errorf("malformed traceparent %q", traceparent)
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return ctx, errors.Errorf("unsupported traceparent version %q", traceparent)
+	}
+	if !isHexID(parts[1], 32) {
+		return ctx, errors.Errorf("bad trace id in traceparent %q", traceparent)
+	}
+	if !isHexID(parts[2], 16) {
+		return ctx, errors.Errorf("bad parent id in traceparent %q", traceparent)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return ctx, errors.Errorf("bad flags in traceparent %q", traceparent)
+	}
+	return withSpan(ctx, &spanContext{
+		traceID: parts[1],
+		spanID: parts[2],
+		flags: flags[0],
+	}), nil
+}
+
+func (l *Logger) WithTraceBegin(begin bool) *Logger {
+	l = l.Clone()
+	l.SetTraceBegin(begin)
+	return l
+}
+
+// SetTraceBegin sets whether traced functions log an event, with an
+// event=begin field, when they start as well as when they end.
+func (l *Logger) SetTraceBegin(begin bool) {
+	l.update(func(cfg *loggerConfig) { cfg.traceBegin = begin })
+}
+
+func (l *Logger) TraceBegin() bool {
+	return l.cfg().traceBegin
+}
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// errorFields describes err, with its stack if it has one from
+// github.com/pkg/errors
+func errorFields(err error) Fields {
+	fields := Fields{Field{Key: "error", Value: err.Error()}}
+	var st stackTracer
+	if errors.As(err, &st) {
+		fields = append(fields, Field{Key: "stack", Value: fmt.Sprintf("%+v", st)})
+	}
+	return fields
+}
+
+func (l *Logger) writeTrace(ctx context.Context, level LogLevel, sr *SourceRecord, msg string, span *spanContext, dur time.Duration, fields Fields) {
+	if level > l.threshold() {
+		return
+	}
+	rec := l.NewRecord(ctx, level, sr, msg)
+	if !l.recordAllowed(rec) {
+		return
+	}
+	rec.Trace = &TraceInfo{
+		TraceID: span.traceID,
+		ParentID: span.parentID,
+		ID: span.spanID,
+		Duration: dur,
+	}
+	rec.Fields = rec.Fields.with(fields...)
+	rec.config.async.flush()
+	l.WriteRecord(rec)
+}
+
+// RawTrace runs fnc in a new span, which continues the trace in ctx if
+// there is one, and logs its duration at TRACE level.  If fnc fails, the
+// error is logged at ERROR level instead, and if it panics, the panic is
+// logged at ERROR level with a stack trace before it is raised again.
+func (l *Logger) RawTrace(ctx context.Context, fnc TraceFunc, msg string) error {
+	return l.rawTrace(deepen(ctx), fnc, func() string { return msg })
+}
+
+func (l *Logger) rawTrace(ctx context.Context, fnc TraceFunc, msgf func() string) error {
+	span := newChildSpan(ctx)
+	childCtx := withDepth(withSpan(ctx, span), 0)
+	threshold := l.threshold()
+	if threshold < ERROR {
+		return fnc(childCtx)
 	}
-	err := f(myCtx)
-	end := time.Now()
+	sr := NewSourceRecord(getDepth(ctx) + 1)
+	msg := ""
+	if threshold >= TRACE {
+		msg = msgf()
+		if l.cfg().traceBegin {
+			l.writeTrace(ctx, TRACE, sr, msg, span, 0, Fields{Field{Key: "event", Value: "begin"}})
+		}
+	}
+	start := time.Now()
+	done := false
+	defer func() {
+		if done {
+			return
+		}
+		p := recover()
+		if p == nil {
+			return
+		}
+		if threshold < TRACE {
+			msg = msgf()
+		}
+		fields := Fields{
+			Field{Key: "panic", Value: p},
+			Field{Key: "stack", Value: string(debug.Stack())},
+		}
+		l.writeTrace(ctx, ERROR, sr, msg, span, time.Since(start), fields)
+		panic(p)
+	}()
+	err := fnc(childCtx)
+	done = true
+	dur := time.Since(start)
 	if err != nil {
-		logger.Infof("ERROR    %s %s %09.6fs %s %s", id, parentId, end.Sub(start).Seconds(), xname, err)
+		if threshold < TRACE {
+			msg = msgf()
+		}
+		l.writeTrace(ctx, ERROR, sr, msg, span, dur, errorFields(err))
 	} else {
-		logger.Infof("COMPLETE %s %s %09.6fs %s", id, parentId, end.Sub(start).Seconds(), xname)
+		l.writeTrace(ctx, TRACE, sr, msg, span, dur, nil)
 	}
 	return err
 }
-*/
+
+func (l *Logger) Trace(ctx context.Context, fnc TraceFunc, args ...interface{}) error {
+	return l.rawTrace(deepen(ctx), fnc, func() string { return fmt.Sprint(args...) })
+}
+
+func (l *Logger) Traceln(ctx context.Context, fnc TraceFunc, args ...interface{}) error {
+	return l.rawTrace(deepen(ctx), fnc, func() string { return fmt.Sprintln(args...) })
+}
+
+func (l *Logger) Tracef(ctx context.Context, fnc TraceFunc, format string, args ...interface{}) error {
+	return l.rawTrace(deepen(ctx), fnc, func() string { return fmt.Sprintf(format, args...) })
+}
@@ -0,0 +1,215 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+type TraceSuite struct {}
+var _ = Suite(&TraceSuite{})
+
+func (a *TraceSuite) TestContextTraceID(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFlags(log.Lshortfile)
+	ctx := NewContext(context.Background(), l)
+	c.Check(TraceID(ctx), Equals, "")
+	c.Check(TraceID(nil), Equals, "")
+	c.Check(SpanID(ctx), Equals, "")
+	Infoln(ctx, "before")
+	var traceId, innerTraceId, outer, inner string
+	Trace(ctx, func(ctx context.Context) error {
+		traceId = TraceID(ctx)
+		outer = SpanID(ctx)
+		Infoln(ctx, "outer")
+		return Trace(ctx, func(ctx context.Context) error {
+			innerTraceId = TraceID(ctx)
+			inner = SpanID(ctx)
+			Warnf(ctx, "%s", "inner")
+			return nil
+		}, "inner trace")
+	}, "outer trace")
+	Infoln(ctx, "after")
+	l.Flush()
+	c.Check(traceId, Matches, `[0-9a-f]{32}`)
+	c.Check(innerTraceId, Equals, traceId)
+	c.Check(outer, Matches, `[0-9a-f]{16}`)
+	c.Check(inner, Matches, `[0-9a-f]{16}`)
+	c.Check(inner, Not(Equals), outer)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 6)
+	t := regexp.QuoteMeta(traceId)
+	c.Check(lines[0], Matches, `INFO     trace_test.go:\d+: before`)
+	c.Check(lines[1], Matches, `INFO     ` + t + ` - ` + outer + ` trace_test.go:\d+: outer`)
+	c.Check(lines[2], Matches, `WARNING  ` + t + ` ` + outer + ` ` + inner + ` trace_test.go:\d+: inner`)
+	c.Check(lines[3], Matches, `TRACE    ` + t + ` ` + outer + ` ` + inner + ` [0-9.]+s trace_test.go:\d+: inner trace`)
+	c.Check(lines[4], Matches, `TRACE    ` + t + ` - ` + outer + ` [0-9.]+s trace_test.go:\d+: outer trace`)
+	c.Check(lines[5], Matches, `INFO     trace_test.go:\d+: after`)
+}
+
+func (a *TraceSuite) TestTraceDisabled(c *C) {
+	l := NewLogger(nil, INFO)
+	var traceId string
+	err := l.Trace(nil, func(ctx context.Context) error {
+		traceId = TraceID(ctx)
+		return errors.New("failed")
+	}, "not logged")
+	c.Check(err, ErrorMatches, "failed")
+	c.Check(traceId, Matches, `[0-9a-f]{32}`)
+}
+
+func (a *TraceSuite) TestStructuredTraceID(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFormatter(NewJSONFormatter())
+	ctx := NewContext(context.Background(), l)
+	var traceId, spanId string
+	Trace(ctx, func(ctx context.Context) error {
+		traceId = TraceID(ctx)
+		spanId = SpanID(ctx)
+		Infoln(ctx, "working")
+		return nil
+	}, "traced")
+	l.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 2)
+	obj := map[string]interface{}{}
+	c.Assert(json.Unmarshal([]byte(lines[0]), &obj), IsNil)
+	c.Check(obj["msg"], Equals, "working")
+	c.Check(obj["trace_id"], Equals, traceId)
+	c.Check(obj["span_id"], Equals, spanId)
+	_, ok := obj["trace_parent"]
+	c.Check(ok, Equals, false)
+	_, ok = obj["duration"]
+	c.Check(ok, Equals, false)
+}
+
+func (a *TraceSuite) TestTraceparent(c *C) {
+	c.Check(TraceparentFromContext(nil), Equals, "")
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx, err := ContextWithTraceparent(context.Background(), header)
+	c.Assert(err, IsNil)
+	c.Check(TraceID(ctx), Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Check(SpanID(ctx), Equals, "00f067aa0ba902b7")
+	c.Check(TraceparentFromContext(ctx), Equals, header)
+	l := NewLogger(nil, INFO)
+	l.Trace(ctx, func(ctx context.Context) error {
+		c.Check(TraceID(ctx), Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+		c.Check(traceInfo(ctx).ParentID, Equals, "00f067aa0ba902b7")
+		c.Check(TraceparentFromContext(ctx), Matches, `00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01`)
+		c.Check(TraceparentFromContext(ctx), Not(Equals), header)
+		return nil
+	})
+	ctx, err = ContextWithTraceparent(nil, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	c.Assert(err, IsNil)
+	l.Trace(ctx, func(ctx context.Context) error {
+		c.Check(TraceparentFromContext(ctx), Matches, `00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-00`)
+		return nil
+	})
+	// later versions may append fields
+	ctx, err = ContextWithTraceparent(nil, "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds")
+	c.Check(err, IsNil)
+	c.Check(TraceparentFromContext(ctx), Equals, header)
+	bad := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"0g-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	}
+	for _, h := range bad {
+		ctx, err := ContextWithTraceparent(context.Background(), h)
+		c.Check(err, NotNil, Commentf("%q", h))
+		c.Check(TraceID(ctx), Equals, "", Commentf("%q", h))
+	}
+}
+
+func (a *TraceSuite) TestTraceBegin(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	l.SetFlags(log.Lshortfile)
+	c.Check(l.TraceBegin(), Equals, false)
+	l.SetTraceBegin(true)
+	c.Check(l.TraceBegin(), Equals, true)
+	c.Check(l.WithTraceBegin(false).TraceBegin(), Equals, false)
+	var traceId, spanId string
+	l.Tracef(NewContext(nil, l), func(ctx context.Context) error {
+		traceId = TraceID(ctx)
+		spanId = SpanID(ctx)
+		Infoln(ctx, "working")
+		return nil
+	}, "job %d", 7)
+	l.Flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, HasLen, 3)
+	c.Check(lines[0], Matches, `TRACE    ` + traceId + ` - ` + spanId + ` trace_test.go:\d+: job 7 event=begin`)
+	c.Check(lines[1], Matches, `INFO     ` + traceId + ` - ` + spanId + ` trace_test.go:\d+: working`)
+	c.Check(lines[2], Matches, `TRACE    ` + traceId + ` - ` + spanId + ` [0-9.]+s trace_test.go:\d+: job 7`)
+}
+
+func (a *TraceSuite) TestTraceError(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, INFO)
+	l.SetFormatter(NewJSONFormatter())
+	err := l.RawTrace(nil, func(ctx context.Context) error {
+		return nil
+	}, "ok")
+	c.Check(err, IsNil)
+	err = l.RawTrace(nil, func(ctx context.Context) error {
+		return fmt.Errorf("plain failure")
+	}, "plain")
+	c.Check(err, ErrorMatches, "plain failure")
+	err = l.Trace(nil, func(ctx context.Context) error {
+		return errors.Wrap(errors.New("disk full"), "can't save")
+	}, "saving")
+	c.Check(err, ErrorMatches, "can't save: disk full")
+	l.Flush()
+	objs := jsonLines(c, bytes.NewBuffer(buf.Bytes()))
+	c.Assert(objs, HasLen, 2)
+	c.Check(objs[0]["level"], Equals, "ERROR")
+	c.Check(objs[0]["msg"], Equals, "plain")
+	c.Check(objs[0]["error"], Equals, "plain failure")
+	c.Check(objs[0]["file"], Matches, `.*/trace_test.go`)
+	c.Check(objs[0]["duration"], NotNil)
+	_, ok := objs[0]["stack"]
+	c.Check(ok, Equals, false)
+	c.Check(objs[1]["level"], Equals, "ERROR")
+	c.Check(objs[1]["msg"], Equals, "saving")
+	c.Check(objs[1]["error"], Equals, "can't save: disk full")
+	c.Check(objs[1]["file"], Matches, `.*/trace_test.go`)
+	c.Check(objs[1]["stack"], Matches, `(?s)disk full\n.*TraceSuite\)\.TestTraceError.*can't save.*`)
+}
+
+func (a *TraceSuite) TestTracePanic(c *C) {
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, WARNING)
+	l.SetFlags(log.Lshortfile)
+	l.SetTraceBegin(true)
+	var spanId string
+	func() {
+		defer func() {
+			c.Check(recover(), Equals, "boom")
+		}()
+		l.Trace(nil, func(ctx context.Context) error {
+			spanId = SpanID(ctx)
+			panic("boom")
+		}, "exploding")
+		c.Fail()
+	}()
+	l.Flush()
+	c.Check(buf.String(), Matches, `(?s)ERROR    [0-9a-f]{32} - ` + spanId + ` [0-9.]+s trace_test.go:\d+: exploding panic=boom stack=".*TraceSuite\)\.TestTracePanic.*"\n`)
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Transport is an http.RoundTripper that runs each outgoing request in a
+// child span of the trace in its context, passing the span on in a
+// traceparent header.  Requests are logged through the logger in their
+// context at DEBUG, or the level set with SetLevel, and transport errors
+// at ERROR.  With SetDump, the headers and bodies of requests and
+// responses are logged too, with credentials and cookies redacted.
+type Transport struct {
+	base http.RoundTripper
+	level LogLevel
+	dump bool
+}
+
+// NewTransport wraps base, or http.DefaultTransport if base is nil.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{base: base, level: DEBUG}
+}
+
+func (t *Transport) getBase() http.RoundTripper {
+	if t.base == nil {
+		return http.DefaultTransport
+	}
+	return t.base
+}
+
+func (t *Transport) SetLevel(level LogLevel) {
+	t.level = level
+}
+
+func (t *Transport) Level() LogLevel {
+	return t.level
+}
+
+func (t *Transport) SetDump(dump bool) {
+	t.dump = dump
+}
+
+func (t *Transport) Dump() bool {
+	return t.dump
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range redactedHeaders {
+		if _, ok := h[k]; ok {
+			h.Set(k, redacted)
+		}
+	}
+	return h
+}
+
+// dumpRequest returns req as it will go out on the wire, leaving its body
+// readable again afterward.  If the body can't be read, req keeps the
+// original body, so closing req.Body on error always closes that.
+func dumpRequest(req *http.Request) (string, error) {
+	dreq := *req
+	dreq.Header = redactHeader(req.Header)
+	data, err := httputil.DumpRequestOut(&dreq, true)
+	req.Body = dreq.Body
+	return string(data), err
+}
+
+// dumpResponse returns res as received, leaving its body readable again
+// afterward.  As with dumpRequest, res.Body is the original body if it
+// couldn't be read.
+func dumpResponse(res *http.Response) (string, error) {
+	dres := *res
+	dres.Header = redactHeader(res.Header)
+	data, err := httputil.DumpResponse(&dres, true)
+	res.Body = dres.Body
+	return string(data), err
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	l := FromContext(ctx)
+	ctx = withSpan(ctx, newChildSpan(ctx))
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", TraceparentFromContext(ctx))
+	dump := t.dump && l.Enabled(t.level)
+	fields := Fields{
+		Field{Key: "method", Value: req.Method},
+		Field{Key: "url", Value: req.URL.Redacted()},
+	}
+	if dump {
+		s, err := dumpRequest(req)
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			t.log(ctx, l, ERROR, fmt.Sprintf("%s %s: can't dump request: %v", req.Method, req.URL.Redacted(), err), fields)
+			return nil, err
+		}
+		fields = append(fields, Field{Key: "request", Value: s})
+	}
+	start := time.Now()
+	res, err := t.getBase().RoundTrip(req)
+	fields = append(fields, Field{Key: "latency", Value: time.Since(start)})
+	if err != nil {
+		fields = append(fields, Field{Key: "error", Value: err})
+		t.log(ctx, l, ERROR, fmt.Sprintf("%s %s: %v", req.Method, req.URL.Redacted(), err), fields)
+		return nil, err
+	}
+	fields = append(fields, Field{Key: "status", Value: res.StatusCode})
+	if dump {
+		s, err := dumpResponse(res)
+		if err != nil {
+			res.Body.Close()
+			t.log(ctx, l, ERROR, fmt.Sprintf("%s %s: can't dump response: %v", req.Method, req.URL.Redacted(), err), fields)
+			return nil, err
+		}
+		fields = append(fields, Field{Key: "response", Value: s})
+	}
+	t.log(ctx, l, t.level, fmt.Sprintf("%s %s %d", req.Method, req.URL.Redacted(), res.StatusCode), fields)
+	return res, nil
+}
+
+func (t *Transport) log(ctx context.Context, l *Logger, level LogLevel, message string, fields Fields) {
+	if !l.Enabled(level) {
+		return
+	}
+	rec := l.NewRecord(ctx, level, NewSourceRecord(1), message)
+	if !l.recordAllowed(rec) {
+		return
+	}
+	rec.Fields = rec.Fields.with(fields...)
+	l.writeAsync(rec)
+}
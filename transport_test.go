@@ -0,0 +1,179 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type TransportSuite struct {}
+var _ = Suite(&TransportSuite{})
+
+func (a *TransportSuite) TestRoundTrip(c *C) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFormatter(NewJSONFormatter())
+	ctx, err := ContextWithTraceparent(NewContext(nil, l), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	c.Assert(err, IsNil)
+	client := &http.Client{Transport: NewTransport(nil)}
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL + "/path?q=1", nil)
+	c.Assert(err, IsNil)
+	res, err := client.Do(req)
+	c.Assert(err, IsNil)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	c.Check(string(body), Equals, "ok")
+	c.Check(req.Header.Get("traceparent"), Equals, "")
+	c.Check(traceparent, Matches, `00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01`)
+	c.Check(traceparent, Not(Equals), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	l.Flush()
+	objs := jsonLines(c, buf)
+	c.Assert(objs, HasLen, 1)
+	c.Check(objs[0]["level"], Equals, "DEBUG")
+	c.Check(objs[0]["msg"], Equals, "GET " + srv.URL + "/path?q=1 200")
+	c.Check(objs[0]["method"], Equals, "GET")
+	c.Check(objs[0]["url"], Equals, srv.URL + "/path?q=1")
+	c.Check(objs[0]["status"], Equals, float64(200))
+	c.Check(objs[0]["latency"], Matches, `[0-9.]+[µnm]?s`)
+	c.Check(objs[0]["trace_id"], Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+	c.Check(objs[0]["trace_parent"], Equals, "00f067aa0ba902b7")
+	c.Check(objs[0]["span_id"], Equals, traceparent[36:52])
+	_, ok := objs[0]["request"]
+	c.Check(ok, Equals, false)
+
+	buf.Reset()
+	l.SetLevel(INFO)
+	res, err = client.Do(req)
+	c.Assert(err, IsNil)
+	res.Body.Close()
+	l.Flush()
+	c.Check(buf.String(), Equals, "")
+}
+
+func (a *TransportSuite) TestLevel(c *C) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, TRACE)
+	t := NewTransport(http.DefaultTransport)
+	c.Check(t.Level(), Equals, DEBUG)
+	t.SetLevel(TRACE)
+	c.Check(t.Level(), Equals, TRACE)
+	req, _ := http.NewRequestWithContext(NewContext(nil, l), "GET", srv.URL, nil)
+	res, err := (&http.Client{Transport: t}).Do(req)
+	c.Assert(err, IsNil)
+	res.Body.Close()
+	l.Flush()
+	c.Check(buf.String(), Matches, `.* TRACE  .*transport.go:\d+: GET \S+ 404 method=GET .*status=404\n`)
+}
+
+func (a *TransportSuite) TestError(c *C) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, ERROR)
+	l.SetFormatter(NewJSONFormatter())
+	req, _ := http.NewRequestWithContext(NewContext(nil, l), "GET", url, nil)
+	_, err := (&http.Client{Transport: NewTransport(nil)}).Do(req)
+	c.Assert(err, NotNil)
+	l.Flush()
+	objs := jsonLines(c, buf)
+	c.Assert(objs, HasLen, 1)
+	c.Check(objs[0]["level"], Equals, "ERROR")
+	c.Check(objs[0]["msg"], Matches, `GET ` + url + `: .*refused.*`)
+	c.Check(objs[0]["error"], Matches, `.*refused.*`)
+	_, ok := objs[0]["status"]
+	c.Check(ok, Equals, false)
+}
+
+func (a *TransportSuite) TestDump(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		c.Check(r.Header.Get("Authorization"), Equals, "Bearer s3cr3t")
+		c.Check(r.Header.Get("Cookie"), Equals, "session=abc")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "xyz"})
+		w.Write([]byte("got " + string(body)))
+	}))
+	defer srv.Close()
+	buf := bytes.NewBuffer([]byte{})
+	l := NewLogger(buf, DEBUG)
+	l.SetFormatter(NewJSONFormatter())
+	t := NewTransport(nil)
+	c.Check(t.Dump(), Equals, false)
+	t.SetDump(true)
+	c.Check(t.Dump(), Equals, true)
+	req, _ := http.NewRequestWithContext(NewContext(context.Background(), l), "POST", srv.URL, strings.NewReader("payload"))
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	req.Header.Set("Cookie", "session=abc")
+	res, err := (&http.Client{Transport: t}).Do(req)
+	c.Assert(err, IsNil)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	c.Check(string(body), Equals, "got payload")
+	c.Check(res.Header.Get("Set-Cookie"), Equals, "session=xyz")
+	l.Flush()
+	objs := jsonLines(c, buf)
+	c.Assert(objs, HasLen, 1)
+	reqDump := objs[0]["request"].(string)
+	c.Check(reqDump, Matches, `(?s)POST / HTTP/1.1\r\n.*Authorization: \[REDACTED\]\r\n.*Cookie: \[REDACTED\]\r\n.*payload`)
+	c.Check(strings.Contains(reqDump, "s3cr3t"), Equals, false)
+	resDump := objs[0]["response"].(string)
+	c.Check(resDump, Matches, `(?s)HTTP/1.1 200 OK\r\n.*Set-Cookie: \[REDACTED\]\r\n.*got payload`)
+	c.Check(strings.Contains(resDump, "xyz"), Equals, false)
+}
+
+type failingBody struct {
+	closed bool
+}
+
+func (b *failingBody) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func (b *failingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type stubTransport func(req *http.Request) (*http.Response, error)
+
+func (f stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (a *TransportSuite) TestDumpBodyErrors(c *C) {
+	l := NewLogger(nil, DEBUG)
+	called := false
+	resBody := &failingBody{}
+	t := NewTransport(stubTransport(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return &http.Response{StatusCode: 200, Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{}, Body: resBody, Request: req}, nil
+	}))
+	t.SetDump(true)
+	reqBody := &failingBody{}
+	req, _ := http.NewRequestWithContext(NewContext(nil, l), "POST", "http://example.com/", reqBody)
+	_, err := t.RoundTrip(req)
+	c.Check(err, ErrorMatches, "read failed")
+	c.Check(called, Equals, false)
+	c.Check(reqBody.closed, Equals, true)
+
+	req, _ = http.NewRequestWithContext(NewContext(nil, l), "GET", "http://example.com/", nil)
+	res, err := t.RoundTrip(req)
+	c.Check(res, IsNil)
+	c.Check(err, ErrorMatches, "read failed")
+	c.Check(called, Equals, true)
+	c.Check(resBody.closed, Equals, true)
+}